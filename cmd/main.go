@@ -11,6 +11,7 @@ import (
 	"github.com/alecthomas/kong"
 	"github.com/crazy-max/ftpgrab/v7/internal/app"
 	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/db"
 	"github.com/crazy-max/ftpgrab/v7/internal/logging"
 	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
 	"github.com/rs/zerolog/log"
@@ -74,11 +75,44 @@ func main() {
 	}
 	log.Debug().Msg(cfg.String())
 
+	// Migrate database entries from another backend
+	if len(cli.DbMigrateFrom) > 0 {
+		from, err := config.ParseDb(cli.DbMigrateFrom)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Cannot parse database to migrate from")
+		}
+		count, err := db.Migrate(from, cfg.Db)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Cannot migrate database")
+		}
+		log.Info().Msgf("%d entries migrated from %s database to %s database", count, from.Type, cfg.Db.Type)
+		return
+	}
+
+	// Upgrade database schema
+	if cli.DbMigrateOnly {
+		version, err := db.Upgrade(cfg.Db)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Cannot migrate database schema")
+		}
+		log.Info().Msgf("Database schema is up to date (version %d)", version)
+		return
+	}
+
 	// Init
 	if ftpgrab, err = app.New(cfg); err != nil {
 		log.Fatal().Err(err).Msgf("Cannot initialize %s", meta.Name)
 	}
 
+	// Reload configuration on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, utl.SIGHUP)
+	go func() {
+		for range reload {
+			ftpgrab.Reload()
+		}
+	}()
+
 	// Start
 	if err = ftpgrab.Start(); err != nil {
 		log.Fatal().Err(err).Msgf("Cannot start %s", meta.Name)
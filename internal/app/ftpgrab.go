@@ -1,6 +1,9 @@
 package app
 
 import (
+	"context"
+	"os"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -12,9 +15,16 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// parser parses CRON expressions with optional seconds
+var parser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
 // FtpGrab represents an active ftpgrab object
 type FtpGrab struct {
 	cfg     *config.Config
+	pending *config.Config
+	mu      sync.Mutex
 	cron    *cron.Cron
 	notif   *notif.Client
 	grabber *grabber.Client
@@ -25,34 +35,37 @@ type FtpGrab struct {
 // New creates new ftpgrab instance
 func New(cfg *config.Config) (*FtpGrab, error) {
 	return &FtpGrab{
-		cfg: cfg,
-		cron: cron.New(cron.WithParser(cron.NewParser(
-			cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor),
-		)),
+		cfg:  cfg,
+		cron: cron.New(cron.WithParser(parser)),
 	}, nil
 }
 
 // Start starts ftpgrab
 func (fg *FtpGrab) Start() error {
-	var err error
-
 	// Run on startup
 	fg.Run()
 
+	cfg := fg.config()
+
+	// Watch configuration file if enabled
+	if cfg.Cli.ConfigWatch && cfg.Cli.ConfigWatchInterval > 0 && len(cfg.File) > 0 {
+		go fg.watchConfig(cfg.File, cfg.Cli.ConfigWatchInterval)
+	}
+
 	// Init scheduler if defined
-	if len(fg.cfg.Cli.Schedule) == 0 {
+	if len(cfg.GetSchedule()) == 0 {
 		return nil
 	}
-	if fg.jobID, err = fg.cron.AddJob(fg.cfg.Cli.Schedule, fg); err != nil {
+	fg.mu.Lock()
+	err := fg.schedule(cfg.GetSchedule())
+	fg.mu.Unlock()
+	if err != nil {
 		return err
 	}
-	log.Info().Msgf("Cron initialized with schedule %s", fg.cfg.Cli.Schedule)
 
 	// Start scheduler
 	fg.cron.Start()
-	log.Info().Msgf("Next run in %s (%s)",
-		durafmt.Parse(time.Until(fg.cron.Entry(fg.jobID).Next)).LimitFirstN(2).String(),
-		fg.cron.Entry(fg.jobID).Next)
+	fg.logNextRun()
 
 	select {}
 }
@@ -64,36 +77,37 @@ func (fg *FtpGrab) Run() {
 		return
 	}
 	defer atomic.StoreUint32(&fg.locker, 0)
-	if fg.jobID > 0 {
-		defer log.Info().Msgf("Next run in %s (%s)",
-			durafmt.Parse(time.Until(fg.cron.Entry(fg.jobID).Next)).LimitFirstN(2).String(),
-			fg.cron.Entry(fg.jobID).Next)
-	}
+	defer fg.logNextRun()
+	fg.applyPending()
+	defer fg.applyPending()
 
 	start := time.Now()
+	cfg := fg.config()
 	var err error
 
 	// Notification client
-	if fg.notif, err = notif.New(fg.cfg.Notif, fg.cfg.Meta); err != nil {
+	if fg.notif, err = notif.New(cfg.Notif, cfg.Meta); err != nil {
 		log.Fatal().Err(err).Msg("Cannot create notifiers")
 	}
 
 	// Grabber client
-	if fg.grabber, err = grabber.New(fg.cfg.Download, fg.cfg.Db, fg.cfg.Server); err != nil {
+	if fg.grabber, err = grabber.New(cfg.Download, cfg.Db, cfg.Server); err != nil {
 		log.Fatal().Err(err).Msg("Cannot create grabber")
 	}
 	defer fg.grabber.Close()
 
-	// List files
-	files := fg.grabber.ListFiles()
-	if len(files) == 0 {
-		log.Warn().Msg("No file found from the provided sources")
-		return
+	// Limit run duration
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if *cfg.Download.MaxDuration > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), *cfg.Download.MaxDuration)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
 	}
-	log.Info().Msgf("%d file(s) found", len(files))
+	defer cancel()
 
-	// Grab
-	jnl := fg.grabber.Grab(files)
+	// List and grab files
+	jnl := fg.grabber.Grab(ctx, fg.grabber.ListFiles(ctx))
 	jnl.Duration = time.Since(start)
 	log.Info().
 		Str("duration", time.Since(start).Round(time.Millisecond).String()).
@@ -109,6 +123,135 @@ func (fg *FtpGrab) Run() {
 	fg.notif.Send(jnl)
 }
 
+// Reload loads and validates configuration again. The new configuration is
+// applied right away if no run is in progress, otherwise once it finishes.
+func (fg *FtpGrab) Reload() {
+	current := fg.config()
+	log.Info().Msg("Reloading configuration")
+
+	cfg, err := config.Load(current.Cli, current.Meta)
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot reload configuration, keeping current one")
+		return
+	}
+	if spec := cfg.GetSchedule(); len(spec) > 0 {
+		if _, err = parser.Parse(spec); err != nil {
+			log.Error().Err(err).Msgf("Cannot parse schedule %s, keeping current configuration", spec)
+			return
+		}
+	}
+
+	fg.mu.Lock()
+	latest := fg.cfg
+	if fg.pending != nil {
+		latest = fg.pending
+	}
+	changes, err := latest.Diff(cfg)
+	if err != nil {
+		fg.mu.Unlock()
+		log.Error().Err(err).Msg("Cannot compare configurations")
+		return
+	}
+	if len(changes) == 0 {
+		fg.mu.Unlock()
+		log.Info().Msg("Configuration unchanged")
+		return
+	}
+	for _, change := range changes {
+		log.Info().Msgf("Configuration changed: %s", change)
+	}
+	fg.pending = cfg
+	fg.mu.Unlock()
+
+	if !atomic.CompareAndSwapUint32(&fg.locker, 0, 1) {
+		log.Info().Msg("Run in progress, new configuration will be applied once finished")
+		return
+	}
+	defer atomic.StoreUint32(&fg.locker, 0)
+	fg.applyPending()
+}
+
+// applyPending swaps the pending configuration in and reschedules runs if
+// the schedule changed. Must be called while holding the run lock so the
+// cron entry is only swapped between runs.
+func (fg *FtpGrab) applyPending() {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	if fg.pending == nil {
+		return
+	}
+
+	previous := fg.cfg.GetSchedule()
+	fg.cfg, fg.pending = fg.pending, nil
+	log.Info().Msg("New configuration applied")
+
+	// Runs are only scheduled once the one on startup is done
+	spec := fg.cfg.GetSchedule()
+	if fg.jobID == 0 || spec == previous {
+		return
+	} else if len(spec) == 0 {
+		log.Warn().Msgf("Schedule cannot be removed without restarting, keeping %s", previous)
+		return
+	}
+	jobID := fg.jobID
+	if err := fg.schedule(spec); err != nil {
+		log.Error().Err(err).Msgf("Cannot reschedule, keeping %s", previous)
+		return
+	}
+	fg.cron.Remove(jobID)
+}
+
+// logNextRun logs when the next scheduled run starts
+func (fg *FtpGrab) logNextRun() {
+	fg.mu.Lock()
+	jobID := fg.jobID
+	fg.mu.Unlock()
+	if jobID == 0 {
+		return
+	}
+	next := fg.cron.Entry(jobID).Next
+	log.Info().Msgf("Next run in %s (%s)",
+		durafmt.Parse(time.Until(next)).LimitFirstN(2).String(),
+		next)
+}
+
+func (fg *FtpGrab) schedule(spec string) error {
+	jobID, err := fg.cron.AddJob(spec, fg)
+	if err != nil {
+		return err
+	}
+	fg.jobID = jobID
+	log.Info().Msgf("Cron initialized with schedule %s", spec)
+	return nil
+}
+
+func (fg *FtpGrab) config() *config.Config {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	return fg.cfg
+}
+
+// watchConfig polls the configuration file and reloads it on change
+func (fg *FtpGrab) watchConfig(filename string, interval time.Duration) {
+	log.Info().Msgf("Watching configuration file %s", filename)
+	var lastMod time.Time
+	if fi, err := os.Stat(filename); err == nil {
+		lastMod = fi.ModTime()
+	}
+	for range time.Tick(interval) {
+		fi, err := os.Stat(filename)
+		if err != nil {
+			log.Warn().Err(err).Msg("Cannot stat configuration file")
+			continue
+		}
+		if fi.ModTime().Equal(lastMod) {
+			continue
+		}
+		lastMod = fi.ModTime()
+		fg.Reload()
+	}
+}
+
 // Close closes ftpgrab
 func (fg *FtpGrab) Close() {
 	fg.grabber.Close()
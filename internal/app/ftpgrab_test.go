@@ -0,0 +1,82 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scheduled returns a ftpgrab instance with runs scheduled on spec
+func scheduled(t *testing.T, spec string) *FtpGrab {
+	t.Helper()
+
+	fg, err := New(&config.Config{Schedule: spec})
+	require.NoError(t, err)
+	require.NoError(t, fg.schedule(fg.cfg.GetSchedule()))
+	return fg
+}
+
+func TestApplyPendingReschedule(t *testing.T) {
+	fg := scheduled(t, "0 0 * * *")
+	jobID := fg.jobID
+
+	fg.pending = &config.Config{Schedule: "*/5 * * * *"}
+	fg.applyPending()
+	assert.Nil(t, fg.pending)
+	assert.Equal(t, "*/5 * * * *", fg.cfg.Schedule)
+	assert.NotEqual(t, jobID, fg.jobID)
+	require.Len(t, fg.cron.Entries(), 1)
+
+	expected, err := parser.Parse("*/5 * * * *")
+	require.NoError(t, err)
+	assert.Equal(t, expected, fg.cron.Entry(fg.jobID).Schedule)
+}
+
+func TestApplyPendingSameSchedule(t *testing.T) {
+	fg := scheduled(t, "0 0 * * *")
+	jobID := fg.jobID
+
+	fg.pending = &config.Config{Schedule: "0 0 * * *"}
+	fg.applyPending()
+	assert.Equal(t, jobID, fg.jobID)
+	assert.Len(t, fg.cron.Entries(), 1)
+}
+
+func TestApplyPendingScheduleFlag(t *testing.T) {
+	cli := config.Cli{Schedule: "0 0 * * *"}
+	fg, err := New(&config.Config{Cli: cli})
+	require.NoError(t, err)
+	require.NoError(t, fg.schedule(fg.cfg.GetSchedule()))
+	jobID := fg.jobID
+
+	// The flag takes precedence over the configuration key
+	fg.pending = &config.Config{Cli: cli, Schedule: "*/5 * * * *"}
+	fg.applyPending()
+	assert.Equal(t, jobID, fg.jobID)
+	assert.Len(t, fg.cron.Entries(), 1)
+}
+
+func TestApplyPendingScheduleRemoved(t *testing.T) {
+	fg := scheduled(t, "0 0 * * *")
+	jobID := fg.jobID
+
+	fg.pending = &config.Config{}
+	fg.applyPending()
+	assert.Empty(t, fg.cfg.Schedule)
+	assert.Equal(t, jobID, fg.jobID)
+	assert.Len(t, fg.cron.Entries(), 1)
+}
+
+func TestApplyPendingNotScheduled(t *testing.T) {
+	fg, err := New(&config.Config{})
+	require.NoError(t, err)
+
+	// Runs are scheduled by Start once the run on startup is done
+	fg.pending = &config.Config{Schedule: "*/5 * * * *"}
+	fg.applyPending()
+	assert.Equal(t, "*/5 * * * *", fg.cfg.GetSchedule())
+	assert.Zero(t, fg.jobID)
+	assert.Empty(t, fg.cron.Entries())
+}
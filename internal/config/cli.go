@@ -1,15 +1,27 @@
 package config
 
-import "github.com/alecthomas/kong"
+import (
+	"time"
+
+	"github.com/alecthomas/kong"
+)
 
 // Cli holds command line args, flags and cmds
 type Cli struct {
-	Version      kong.VersionFlag
-	Cfgfile      string `kong:"name='config',env='CONFIG',help='FTPGrab configuration file.'"`
-	Schedule     string `kong:"name='schedule',env='SCHEDULE',help='CRON expression format.'"`
-	LogLevel     string `kong:"name='log-level',env='LOG_LEVEL',default='info',help='Set log level.'"`
-	LogJSON      bool   `kong:"name='log-json',env='LOG_JSON',default='false',help='Enable JSON logging output.'"`
-	LogTimestamp bool   `kong:"name='log-timestamp',env='LOG_TIMESTAMP',default='true',help='Adds the current local time as UNIX timestamp to the logger context.'"`
-	LogCaller    bool   `kong:"name='log-caller',env='LOG_CALLER',default='false',help='Add file:line of the caller to log output.'"`
-	LogFile      string `kong:"name='log-file',env='LOG_FILE',help='Add logging to a specific file.'"`
+	Version             kong.VersionFlag
+	Cfgfile             string        `kong:"name='config',env='CONFIG',help='FTPGrab configuration file.'"`
+	ConfigWatch         bool          `kong:"name='config-watch',env='CONFIG_WATCH',default='false',help='Reload configuration when the file changes.'"`
+	ConfigWatchInterval time.Duration `kong:"name='config-watch-interval',env='CONFIG_WATCH_INTERVAL',default='10s',help='Interval to check configuration file changes.'"`
+	Schedule            string        `kong:"name='schedule',env='SCHEDULE',help='CRON expression format.'"`
+	DbMigrateFrom       string        `kong:"name='db-migrate-from',env='DB_MIGRATE_FROM',help='Copy entries from another database (bolt:path, sqlite:path or redis://host:port/db) to the configured one and exit.'"`
+	DbMigrateOnly       bool          `kong:"name='db-migrate-only',env='DB_MIGRATE_ONLY',default='false',help='Upgrade the database schema and exit.'"`
+	LogLevel            string        `kong:"name='log-level',env='LOG_LEVEL',default='info',help='Set log level.'"`
+	LogJSON             bool          `kong:"name='log-json',env='LOG_JSON',default='false',help='Enable JSON logging output.'"`
+	LogTimestamp        bool          `kong:"name='log-timestamp',env='LOG_TIMESTAMP',default='true',help='Adds the current local time as UNIX timestamp to the logger context.'"`
+	LogCaller           bool          `kong:"name='log-caller',env='LOG_CALLER',default='false',help='Add file:line of the caller to log output.'"`
+	LogFile             string        `kong:"name='log-file',env='LOG_FILE',help='Add logging to a specific file.'"`
+	LogSyslog           string        `kong:"name='log-syslog',env='LOG_SYSLOG',help='Add logging to a syslog server (udp://host:port, tcp://host:port or unix:///path).'"`
+	LogSyslogFacility   string        `kong:"name='log-syslog-facility',env='LOG_SYSLOG_FACILITY',default='daemon',help='Syslog facility.'"`
+	LogSyslogTag        string        `kong:"name='log-syslog-tag',env='LOG_SYSLOG_TAG',default='ftpgrab',help='Syslog tag (also used as journald identifier).'"`
+	LogJournald         bool          `kong:"name='log-journald',env='LOG_JOURNALD',default='false',help='Add logging to the systemd journal.'"`
 }
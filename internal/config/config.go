@@ -4,10 +4,10 @@ import (
 	"encoding/json"
 	"os"
 	"path"
-	"regexp"
-	"time"
+	"text/template"
 
 	"github.com/crazy-max/gonfig"
+	"github.com/crazy-max/gonfig/file"
 	"github.com/go-playground/validator/v10"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -17,6 +17,8 @@ import (
 type Config struct {
 	Cli      Cli       `yaml:"-" json:"-" label:"-" file:"-"`
 	Meta     Meta      `yaml:"-" json:"-" label:"-" file:"-"`
+	File     string    `yaml:"-" json:"-" label:"-" file:"-"`
+	Schedule string    `yaml:"schedule,omitempty" json:"schedule,omitempty" validate:"omitempty"`
 	Db       *Db       `yaml:"db,omitempty" json:"db,omitempty" validate:"omitempty"`
 	Server   *Server   `yaml:"server,omitempty" json:"server,omitempty" validate:"required"`
 	Download *Download `yaml:"download,omitempty" json:"download,omitempty" validate:"required"`
@@ -31,19 +33,19 @@ func Load(cli Cli, meta Meta) (*Config, error) {
 		Db:   (&Db{}).GetDefaults(),
 	}
 
-	fileLoader := gonfig.NewFileLoader(gonfig.FileLoaderConfig{
-		Filename: cli.Cfgfile,
-		Finder: gonfig.Finder{
-			BasePaths:  []string{"/etc/ftpgrab/ftpgrab", "$XDG_CONFIG_HOME/ftpgrab", "$HOME/.config/ftpgrab", "./ftpgrab"},
-			Extensions: []string{"yaml", "yml"},
-		},
-	})
-	if found, err := fileLoader.Load(&cfg); err != nil {
+	finder := gonfig.Finder{
+		BasePaths:  []string{"/etc/ftpgrab/ftpgrab", "$XDG_CONFIG_HOME/ftpgrab", "$HOME/.config/ftpgrab", "./ftpgrab"},
+		Extensions: []string{"yaml", "yml"},
+	}
+	if filename, err := finder.Find(cli.Cfgfile); err != nil {
 		return nil, errors.Wrap(err, "Failed to decode configuration from file")
-	} else if !found {
+	} else if len(filename) == 0 {
 		log.Debug().Msg("No configuration file found")
+	} else if err = decodeFile(filename, &cfg); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode configuration from file")
 	} else {
-		log.Info().Msgf("Configuration loaded from file: %s", fileLoader.GetFilename())
+		cfg.File = filename
+		log.Info().Msgf("Configuration loaded from file: %s", cfg.File)
 	}
 
 	envLoader := gonfig.NewEnvLoader(gonfig.EnvLoaderConfig{
@@ -57,6 +59,18 @@ func Load(cli Cli, meta Meta) (*Config, error) {
 		log.Info().Msgf("Configuration loaded from %d environment variables", len(envLoader.GetVars()))
 	}
 
+	// Sources can still be defined as a comma separated list of paths
+	if cfg.Server != nil && cfg.Server.FTP != nil {
+		if sources := os.Getenv("FTPGRAB_SERVER_FTP_SOURCES"); len(sources) > 0 {
+			cfg.Server.FTP.Sources = sourcesFromList(sources)
+		}
+	}
+	if cfg.Server != nil && cfg.Server.SFTP != nil {
+		if sources := os.Getenv("FTPGRAB_SERVER_SFTP_SOURCES"); len(sources) > 0 {
+			cfg.Server.SFTP.Sources = sourcesFromList(sources)
+		}
+	}
+
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
@@ -64,11 +78,30 @@ func Load(cli Cli, meta Meta) (*Config, error) {
 	return &cfg, nil
 }
 
-func (cfg *Config) validate() error {
-	var err error
+// decodeFile decodes a configuration file into cfg
+func decodeFile(filename string, cfg *Config) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if content, err = normalizeSources(content); err != nil {
+		return err
+	}
+	return file.DecodeContent(string(content), path.Ext(filename), cfg)
+}
 
+// GetSchedule returns the CRON expression used to schedule runs. The
+// schedule flag takes precedence over the one in configuration.
+func (cfg *Config) GetSchedule() string {
+	if len(cfg.Cli.Schedule) > 0 {
+		return cfg.Cli.Schedule
+	}
+	return cfg.Schedule
+}
+
+func (cfg *Config) validate() error {
 	if cfg.Db != nil {
-		if len(cfg.Db.Path) > 0 {
+		if cfg.Db.Type != "redis" && len(cfg.Db.Path) > 0 {
 			if err := os.MkdirAll(path.Dir(cfg.Db.Path), os.ModePerm); err != nil {
 				return errors.Wrap(err, "Cannot create database destination folder")
 			}
@@ -85,33 +118,43 @@ func (cfg *Config) validate() error {
 			if len(cfg.Server.FTP.Sources) == 0 {
 				return errors.New("At least one FTP source is required")
 			}
+			if err := cfg.Server.FTP.validate(); err != nil {
+				return err
+			}
 		}
 		if cfg.Server.SFTP != nil {
 			if len(cfg.Server.SFTP.Sources) == 0 {
 				return errors.New("At least one SFTP source is required")
 			}
+			if err := cfg.Server.SFTP.validate(); err != nil {
+				return err
+			}
 		}
 	}
 
 	if cfg.Download != nil {
-		if err := os.MkdirAll(cfg.Download.Output, os.ModePerm); err != nil {
-			return errors.Wrap(err, "Cannot create download output folder")
+		if err := cfg.Download.validate(); err != nil {
+			return err
 		}
-		for _, include := range cfg.Download.Include {
-			if _, err := regexp.Compile(include); err != nil {
-				return errors.Wrapf(err, "Include regex '%s' cannot compile", include)
-			}
+		dbEnabled := cfg.Db.Enabled()
+		if cfg.Download.Incremental && !dbEnabled {
+			return errors.New("Incremental since requires the database")
 		}
-		for _, exclude := range cfg.Download.Exclude {
-			if _, err := regexp.Compile(exclude); err != nil {
-				return errors.Wrapf(err, "Exclude regex '%s' cannot compile", exclude)
+		if cfg.Server != nil {
+			for _, src := range cfg.Server.Sources() {
+				dl, err := src.Override(cfg.Download)
+				if err != nil {
+					return err
+				} else if dl.Incremental && !dbEnabled {
+					return errors.Errorf("Incremental since of source %s requires the database", src.Path)
+				}
 			}
 		}
-		if len(cfg.Download.Since) > 0 {
-			cfg.Download.SinceTime, err = time.Parse("2006-01-02T15:04:05Z", cfg.Download.Since)
-			if err != nil {
-				return err
-			}
+	}
+
+	if cfg.Notif != nil && cfg.Notif.Webhook != nil && len(cfg.Notif.Webhook.Template) > 0 {
+		if _, err := template.New("body").Parse(cfg.Notif.Webhook.Template); err != nil {
+			return errors.Wrap(err, "Webhook template cannot be parsed")
 		}
 	}
 
@@ -1,8 +1,18 @@
 package config
 
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 // Db holds data necessary for database configuration
 type Db struct {
-	Path string `yaml:"path,omitempty" json:"path,omitempty" validate:"required"`
+	Type  string   `yaml:"type,omitempty" json:"type,omitempty" validate:"required,oneof=bolt sqlite redis"`
+	Path  string   `yaml:"path,omitempty" json:"path,omitempty" validate:"required_unless=Type redis"`
+	Redis *DbRedis `yaml:"redis,omitempty" json:"redis,omitempty" validate:"required_if=Type redis"`
 }
 
 // GetDefaults gets the default values
@@ -14,5 +24,52 @@ func (s *Db) GetDefaults() *Db {
 
 // SetDefaults sets the default values
 func (s *Db) SetDefaults() {
+	s.Type = "bolt"
 	s.Path = "ftpgrab.db"
 }
+
+// Enabled verifies if the database is enabled
+func (s *Db) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	if s.Type == "redis" {
+		return s.Redis != nil
+	}
+	return len(s.Path) > 0
+}
+
+// ParseDb parses a database location given as type:path for bolt and
+// sqlite, or as a redis://[user:password@]host:port[/db] URL
+func ParseDb(location string) (*Db, error) {
+	dbType, dbPath, ok := strings.Cut(location, ":")
+	if !ok || len(dbPath) == 0 {
+		return nil, errors.Errorf("Invalid database location %s", location)
+	}
+
+	switch dbType {
+	case "bolt", "sqlite":
+		return &Db{Type: dbType, Path: dbPath}, nil
+	case "redis":
+		u, err := url.Parse(location)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid database location %s", location)
+		}
+		redis := (&DbRedis{}).GetDefaults()
+		if len(u.Host) > 0 {
+			redis.Addr = u.Host
+		}
+		if u.User != nil {
+			redis.Username = u.User.Username()
+			redis.Password, _ = u.User.Password()
+		}
+		if dbNum := strings.Trim(u.Path, "/"); len(dbNum) > 0 {
+			if redis.DB, err = strconv.Atoi(dbNum); err != nil {
+				return nil, errors.Errorf("Invalid redis database %s", dbNum)
+			}
+		}
+		return &Db{Type: dbType, Redis: redis}, nil
+	default:
+		return nil, errors.Errorf("Unknown database type %s", dbType)
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+// DbRedis holds redis database configuration
+type DbRedis struct {
+	Addr         string `yaml:"addr,omitempty" json:"addr,omitempty" validate:"required"`
+	Username     string `yaml:"username,omitempty" json:"username,omitempty"`
+	UsernameFile string `yaml:"usernameFile,omitempty" json:"usernameFile,omitempty" validate:"omitempty,file"`
+	Password     string `yaml:"password,omitempty" json:"password,omitempty"`
+	PasswordFile string `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty" validate:"omitempty,file"`
+	DB           int    `yaml:"db,omitempty" json:"db,omitempty" validate:"min=0"`
+	KeyPrefix    string `yaml:"keyPrefix,omitempty" json:"keyPrefix,omitempty" validate:"required"`
+}
+
+// GetDefaults gets the default values
+func (s *DbRedis) GetDefaults() *DbRedis {
+	n := &DbRedis{}
+	n.SetDefaults()
+	return n
+}
+
+// SetDefaults sets the default values
+func (s *DbRedis) SetDefaults() {
+	s.Addr = "localhost:6379"
+	s.KeyPrefix = "ftpgrab"
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/crazy-max/gonfig/env"
+)
+
+// sensitiveKeys are name fragments of settings whose values must not be
+// logged. Webhook URLs and endpoints often embed a token in their path.
+var sensitiveKeys = []string{"PASSWORD", "PASSPHRASE", "SECRET", "TOKEN", "HEADERS_", "WEBHOOKURL", "ENDPOINT", "PROXY"}
+
+// urlUserinfo matches credentials embedded in URLs
+var urlUserinfo = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://)[^/?#@\s]+@`)
+
+// Diff returns a human readable list of settings that differ from other
+// configuration. Values of sensitive settings and credentials of URLs are
+// masked.
+func (cfg *Config) Diff(other *Config) ([]string, error) {
+	before, err := flatten(cfg)
+	if err != nil {
+		return nil, err
+	}
+	after, err := flatten(other)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]struct{}, len(before)+len(after))
+	for key := range before {
+		keys[key] = struct{}{}
+	}
+	for key := range after {
+		keys[key] = struct{}{}
+	}
+
+	var changes []string
+	for key := range keys {
+		oldValue, newValue := before[key], after[key]
+		if oldValue == newValue {
+			continue
+		}
+		if isSensitive(key) {
+			changes = append(changes, fmt.Sprintf("%s changed", key))
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s: %q => %q", key, maskURL(oldValue), maskURL(newValue)))
+	}
+	sort.Strings(changes)
+
+	return changes, nil
+}
+
+func flatten(cfg *Config) (map[string]string, error) {
+	values := make(map[string]string)
+	if cfg == nil {
+		return values, nil
+	}
+	flats, err := env.Encode("FTPGRAB_", cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, flat := range flats {
+		values[flat.Name] = flat.Default
+	}
+	return values, nil
+}
+
+func isSensitive(key string) bool {
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskURL masks credentials of URLs found in a value
+func maskURL(value string) string {
+	return urlUserinfo.ReplaceAllString(value, "${1}xxxxx@")
+}
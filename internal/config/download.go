@@ -2,26 +2,59 @@ package config
 
 import (
 	"os"
+	"regexp"
+	"text/template"
 	"time"
 
 	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
 )
 
 // Download holds download configuration details
 type Download struct {
-	Output        string      `yaml:"output,omitempty" json:"output,omitempty" validate:"required,dir"`
-	UID           int         `yaml:"uid,omitempty" json:"uid,omitempty"`
-	GID           int         `yaml:"gid,omitempty" json:"gid,omitempty"`
-	ChmodFile     os.FileMode `yaml:"chmodFile,omitempty" json:"chmodFile,omitempty"`
-	ChmodDir      os.FileMode `yaml:"chmodDir,omitempty" json:"chmodDir,omitempty"`
-	Include       []string    `yaml:"include,omitempty" json:"include,omitempty"`
-	Exclude       []string    `yaml:"exclude,omitempty" json:"exclude,omitempty"`
-	Since         string      `yaml:"since,omitempty" json:"since,omitempty"`
-	SinceTime     time.Time   `yaml:"-" json:"-" label:"-" file:"-"`
-	Retry         int         `yaml:"retry,omitempty" json:"retry,omitempty"`
-	HideSkipped   *bool       `yaml:"hideSkipped,omitempty" json:"hideSkipped,omitempty"`
-	TempFirst     *bool       `yaml:"tempFirst,omitempty" json:"tempFirst,omitempty"`
-	CreateBaseDir *bool       `yaml:"createBaseDir,omitempty" json:"createBaseDir,omitempty"`
+	Output         string             `yaml:"output,omitempty" json:"output,omitempty" validate:"required,dir"`
+	UID            int                `yaml:"uid,omitempty" json:"uid,omitempty"`
+	GID            int                `yaml:"gid,omitempty" json:"gid,omitempty"`
+	ChmodFile      os.FileMode        `yaml:"chmodFile,omitempty" json:"chmodFile,omitempty"`
+	ChmodDir       os.FileMode        `yaml:"chmodDir,omitempty" json:"chmodDir,omitempty"`
+	Include        []string           `yaml:"include,omitempty" json:"include,omitempty"`
+	Exclude        []string           `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+	IncludePath    []string           `yaml:"includePath,omitempty" json:"includePath,omitempty"`
+	ExcludePath    []string           `yaml:"excludePath,omitempty" json:"excludePath,omitempty"`
+	ExcludeDir     []string           `yaml:"excludeDir,omitempty" json:"excludeDir,omitempty"`
+	MinSize        string             `yaml:"minSize,omitempty" json:"minSize,omitempty"`
+	MinSizeBytes   int64              `yaml:"-" json:"-" label:"-" file:"-"`
+	MaxSize        string             `yaml:"maxSize,omitempty" json:"maxSize,omitempty"`
+	MaxSizeBytes   int64              `yaml:"-" json:"-" label:"-" file:"-"`
+	MinAge         string             `yaml:"minAge,omitempty" json:"minAge,omitempty"`
+	MinAgeDuration time.Duration      `yaml:"-" json:"-" label:"-" file:"-"`
+	MaxAge         string             `yaml:"maxAge,omitempty" json:"maxAge,omitempty"`
+	MaxAgeDuration time.Duration      `yaml:"-" json:"-" label:"-" file:"-"`
+	Since          string             `yaml:"since,omitempty" json:"since,omitempty"`
+	SinceTime      time.Time          `yaml:"-" json:"-" label:"-" file:"-"`
+	SinceDuration  time.Duration      `yaml:"-" json:"-" label:"-" file:"-"`
+	Incremental    bool               `yaml:"-" json:"-" label:"-" file:"-"`
+	Symlinks       string             `yaml:"symlinks,omitempty" json:"symlinks,omitempty" validate:"required,oneof=skip follow copy-as-link"`
+	ListWorkers    int                `yaml:"listWorkers,omitempty" json:"listWorkers,omitempty" validate:"required,min=1"`
+	Retry          int                `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Backoff        *DownloadBackoff   `yaml:"backoff,omitempty" json:"backoff,omitempty" validate:"required"`
+	FileTimeout    *time.Duration     `yaml:"fileTimeout,omitempty" json:"fileTimeout,omitempty"`
+	IdleTimeout    *time.Duration     `yaml:"idleTimeout,omitempty" json:"idleTimeout,omitempty"`
+	MaxDuration    *time.Duration     `yaml:"maxDuration,omitempty" json:"maxDuration,omitempty"`
+	HideSkipped    *bool              `yaml:"hideSkipped,omitempty" json:"hideSkipped,omitempty"`
+	TempFirst      *bool              `yaml:"tempFirst,omitempty" json:"tempFirst,omitempty"`
+	CreateBaseDir  *bool              `yaml:"createBaseDir,omitempty" json:"createBaseDir,omitempty"`
+	DestTemplate   string             `yaml:"destTemplate,omitempty" json:"destTemplate,omitempty"`
+	DestTpl        *template.Template `yaml:"-" json:"-" label:"-" file:"-"`
+	DestRegex      string             `yaml:"destRegex,omitempty" json:"destRegex,omitempty"`
+	DestRe         *regexp.Regexp     `yaml:"-" json:"-" label:"-" file:"-"`
+	Flatten        *bool              `yaml:"flatten,omitempty" json:"flatten,omitempty"`
+	OnConflict     string             `yaml:"onConflict,omitempty" json:"onConflict,omitempty" validate:"required,oneof=overwrite skip rename backup newer"`
+	ConflictSuffix string             `yaml:"conflictSuffix,omitempty" json:"conflictSuffix,omitempty" validate:"required,oneof=number timestamp"`
+	Extract        []DownloadExtract  `yaml:"extract,omitempty" json:"extract,omitempty" validate:"omitempty,dive"`
+	Decrypt        *DownloadDecrypt   `yaml:"decrypt,omitempty" json:"decrypt,omitempty"`
+	PostAction     string             `yaml:"postAction,omitempty" json:"postAction,omitempty" validate:"required,oneof=none delete"`
 }
 
 // GetDefaults gets the default values
@@ -37,8 +70,123 @@ func (s *Download) SetDefaults() {
 	s.GID = os.Getgid()
 	s.ChmodFile = 0o644
 	s.ChmodDir = 0o755
+	s.Symlinks = "follow"
+	s.ListWorkers = 4
 	s.Retry = 3
+	s.Backoff = (&DownloadBackoff{}).GetDefaults()
+	s.FileTimeout = utl.NewDuration(0)
+	s.IdleTimeout = utl.NewDuration(5 * time.Minute)
+	s.MaxDuration = utl.NewDuration(0)
 	s.HideSkipped = utl.NewFalse()
 	s.TempFirst = utl.NewFalse()
 	s.CreateBaseDir = utl.NewFalse()
+	s.Flatten = utl.NewFalse()
+	s.OnConflict = "overwrite"
+	s.ConflictSuffix = "number"
+	s.PostAction = "none"
+}
+
+// validate checks download settings and parses the ones derived from them
+func (s *Download) validate() error {
+	var err error
+
+	if err := os.MkdirAll(s.Output, os.ModePerm); err != nil {
+		return errors.Wrap(err, "Cannot create download output folder")
+	}
+	for _, include := range s.Include {
+		if _, err := regexp.Compile(include); err != nil {
+			return errors.Wrapf(err, "Include regex '%s' cannot compile", include)
+		}
+	}
+	for _, exclude := range s.Exclude {
+		if _, err := regexp.Compile(exclude); err != nil {
+			return errors.Wrapf(err, "Exclude regex '%s' cannot compile", exclude)
+		}
+	}
+	if len(s.DestTemplate) > 0 {
+		if s.DestTpl, err = template.New("dest").Option("missingkey=zero").Parse(s.DestTemplate); err != nil {
+			return errors.Wrap(err, "Destination template cannot be parsed")
+		}
+	}
+	if len(s.DestRegex) > 0 {
+		if s.DestRe, err = regexp.Compile(s.DestRegex); err != nil {
+			return errors.Wrapf(err, "Destination regex '%s' cannot compile", s.DestRegex)
+		}
+	}
+	for i, extract := range s.Extract {
+		if _, err := regexp.Compile(extract.Include); err != nil {
+			return errors.Wrapf(err, "Extract include regex '%s' cannot compile", extract.Include)
+		}
+		if _, err := template.New("extract").Parse(extract.Dest); err != nil {
+			return errors.Wrapf(err, "Extract destination template '%s' cannot be parsed", extract.Dest)
+		}
+		if len(extract.MaxSize) > 0 {
+			if s.Extract[i].MaxSizeBytes, err = units.FromHumanSize(extract.MaxSize); err != nil {
+				return errors.Wrapf(err, "Cannot parse extract max size '%s'", extract.MaxSize)
+			}
+		}
+	}
+	for _, include := range s.IncludePath {
+		if _, err := regexp.Compile(include); err != nil {
+			return errors.Wrapf(err, "Include path regex '%s' cannot compile", include)
+		}
+	}
+	for _, exclude := range s.ExcludePath {
+		if _, err := regexp.Compile(exclude); err != nil {
+			return errors.Wrapf(err, "Exclude path regex '%s' cannot compile", exclude)
+		}
+	}
+	for _, exclude := range s.ExcludeDir {
+		if _, err := regexp.Compile(exclude); err != nil {
+			return errors.Wrapf(err, "Exclude dir regex '%s' cannot compile", exclude)
+		}
+	}
+	if len(s.MinSize) > 0 {
+		if s.MinSizeBytes, err = units.FromHumanSize(s.MinSize); err != nil {
+			return errors.Wrapf(err, "Cannot parse min size '%s'", s.MinSize)
+		}
+	}
+	if len(s.MaxSize) > 0 {
+		if s.MaxSizeBytes, err = units.FromHumanSize(s.MaxSize); err != nil {
+			return errors.Wrapf(err, "Cannot parse max size '%s'", s.MaxSize)
+		}
+		if s.MaxSizeBytes < s.MinSizeBytes {
+			return errors.New("Max size cannot be lower than min size")
+		}
+	}
+	if len(s.MinAge) > 0 {
+		if s.MinAgeDuration, err = utl.ParseDuration(s.MinAge); err != nil {
+			return errors.Wrapf(err, "Cannot parse min age '%s'", s.MinAge)
+		}
+	}
+	if len(s.MaxAge) > 0 {
+		if s.MaxAgeDuration, err = utl.ParseDuration(s.MaxAge); err != nil {
+			return errors.Wrapf(err, "Cannot parse max age '%s'", s.MaxAge)
+		}
+		if s.MaxAgeDuration < s.MinAgeDuration {
+			return errors.New("Max age cannot be lower than min age")
+		}
+	}
+	if s.Decrypt != nil {
+		for _, include := range s.Decrypt.Include {
+			if _, err := regexp.Compile(include); err != nil {
+				return errors.Wrapf(err, "Decrypt include regex '%s' cannot compile", include)
+			}
+		}
+	}
+	if s.Backoff != nil && *s.Backoff.Max < *s.Backoff.Initial {
+		return errors.New("Max backoff delay cannot be lower than initial delay")
+	}
+	if len(s.Since) > 0 {
+		s.SinceTime, s.SinceDuration, s.Incremental = time.Time{}, 0, false
+		if s.Since == "incremental" {
+			s.Incremental = true
+		} else if s.SinceTime, err = time.Parse("2006-01-02T15:04:05Z", s.Since); err != nil {
+			if s.SinceDuration, err = utl.ParseDuration(s.Since); err != nil || s.SinceDuration <= 0 {
+				return errors.Errorf("Since '%s' must be a date, a positive duration or 'incremental'", s.Since)
+			}
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+)
+
+// DownloadBackoff holds the delay between download retries
+type DownloadBackoff struct {
+	Initial    *time.Duration `yaml:"initial,omitempty" json:"initial,omitempty" validate:"required"`
+	Multiplier float64        `yaml:"multiplier,omitempty" json:"multiplier,omitempty" validate:"min=1"`
+	Max        *time.Duration `yaml:"max,omitempty" json:"max,omitempty" validate:"required"`
+	Jitter     float64        `yaml:"jitter,omitempty" json:"jitter,omitempty" validate:"min=0,max=1"`
+}
+
+// GetDefaults gets the default values
+func (s *DownloadBackoff) GetDefaults() *DownloadBackoff {
+	n := &DownloadBackoff{}
+	n.SetDefaults()
+	return n
+}
+
+// SetDefaults sets the default values
+func (s *DownloadBackoff) SetDefaults() {
+	s.Initial = utl.NewDuration(1 * time.Second)
+	s.Multiplier = 2
+	s.Max = utl.NewDuration(1 * time.Minute)
+	s.Jitter = 0.2
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+)
+
+// DownloadDecrypt holds OpenPGP decryption configuration details
+type DownloadDecrypt struct {
+	Include           []string `yaml:"include,omitempty" json:"include,omitempty" validate:"required,min=1,dive,required"`
+	KeyFile           string   `yaml:"keyFile,omitempty" json:"keyFile,omitempty" validate:"required,file"`
+	KeyPassphrase     string   `yaml:"keyPassphrase,omitempty" json:"keyPassphrase,omitempty"`
+	KeyPassphraseFile string   `yaml:"keyPassphraseFile,omitempty" json:"keyPassphraseFile,omitempty" validate:"omitempty,file"`
+	KeyringFile       string   `yaml:"keyringFile,omitempty" json:"keyringFile,omitempty" validate:"omitempty,file"`
+	DeleteEncrypted   *bool    `yaml:"deleteEncrypted,omitempty" json:"deleteEncrypted,omitempty" validate:"required"`
+}
+
+// GetDefaults gets the default values
+func (s *DownloadDecrypt) GetDefaults() *DownloadDecrypt {
+	n := &DownloadDecrypt{}
+	n.SetDefaults()
+	return n
+}
+
+// SetDefaults sets the default values
+func (s *DownloadDecrypt) SetDefaults() {
+	s.Include = []string{`\.(gpg|pgp)$`}
+	s.DeleteEncrypted = utl.NewFalse()
+}
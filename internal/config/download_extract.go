@@ -0,0 +1,29 @@
+package config
+
+import (
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+)
+
+// DownloadExtract holds archive extraction configuration details
+type DownloadExtract struct {
+	Include       string `yaml:"include,omitempty" json:"include,omitempty" validate:"required"`
+	Dest          string `yaml:"dest,omitempty" json:"dest,omitempty" validate:"required"`
+	DeleteArchive *bool  `yaml:"deleteArchive,omitempty" json:"deleteArchive,omitempty" validate:"required"`
+	MaxSize       string `yaml:"maxSize,omitempty" json:"maxSize,omitempty"`
+	MaxSizeBytes  int64  `yaml:"-" json:"-" label:"-" file:"-"`
+	MaxRatio      int    `yaml:"maxRatio,omitempty" json:"maxRatio,omitempty" validate:"min=0"`
+}
+
+// GetDefaults gets the default values
+func (s *DownloadExtract) GetDefaults() *DownloadExtract {
+	n := &DownloadExtract{}
+	n.SetDefaults()
+	return n
+}
+
+// SetDefaults sets the default values
+func (s *DownloadExtract) SetDefaults() {
+	s.Dest = "{{ .Basename }}"
+	s.DeleteArchive = utl.NewFalse()
+	s.MaxRatio = 100
+}
@@ -3,6 +3,7 @@ package config
 // Notif holds data necessary for notification configuration
 type Notif struct {
 	Mail    *NotifMail    `yaml:"mail,omitempty" json:"mail,omitempty"`
+	MQTT    *NotifMQTT    `yaml:"mqtt,omitempty" json:"mqtt,omitempty"`
 	Script  *NotifScript  `yaml:"script,omitempty" json:"script,omitempty"`
 	Slack   *NotifSlack   `yaml:"slack,omitempty" json:"slack,omitempty"`
 	Webhook *NotifWebhook `yaml:"webhook,omitempty" json:"webhook,omitempty"`
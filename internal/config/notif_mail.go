@@ -6,16 +6,21 @@ import (
 
 // NotifMail holds mail notification configuration details
 type NotifMail struct {
-	Host               string `yaml:"host,omitempty" json:"host,omitempty" validate:"required"`
-	Port               int    `yaml:"port,omitempty" json:"port,omitempty" validate:"required,min=1"`
-	SSL                *bool  `yaml:"ssl,omitempty" json:"ssl,omitempty" validate:"required"`
-	InsecureSkipVerify *bool  `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty" validate:"required"`
-	Username           string `yaml:"username,omitempty" json:"username,omitempty" validate:"omitempty"`
-	UsernameFile       string `yaml:"usernameFile,omitempty" json:"usernameFile,omitempty" validate:"omitempty,file"`
-	Password           string `yaml:"password,omitempty" json:"password,omitempty" validate:"omitempty"`
-	PasswordFile       string `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty" validate:"omitempty,file"`
-	From               string `yaml:"from,omitempty" json:"from,omitempty" validate:"required,email"`
-	To                 string `yaml:"to,omitempty" json:"to,omitempty" validate:"required,email"`
+	Host               string   `yaml:"host,omitempty" json:"host,omitempty" validate:"required"`
+	Port               int      `yaml:"port,omitempty" json:"port,omitempty" validate:"required,min=1"`
+	SSL                *bool    `yaml:"ssl,omitempty" json:"ssl,omitempty" validate:"required"`
+	StartTLS           string   `yaml:"startTLS,omitempty" json:"startTLS,omitempty" validate:"required,oneof=mandatory opportunistic none"`
+	InsecureSkipVerify *bool    `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty" validate:"required"`
+	CAFile             string   `yaml:"caFile,omitempty" json:"caFile,omitempty" validate:"omitempty,file"`
+	Username           string   `yaml:"username,omitempty" json:"username,omitempty" validate:"omitempty"`
+	UsernameFile       string   `yaml:"usernameFile,omitempty" json:"usernameFile,omitempty" validate:"omitempty,file"`
+	Password           string   `yaml:"password,omitempty" json:"password,omitempty" validate:"omitempty"`
+	PasswordFile       string   `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty" validate:"omitempty,file"`
+	From               string   `yaml:"from,omitempty" json:"from,omitempty" validate:"required,email"`
+	To                 []string `yaml:"to,omitempty" json:"to,omitempty" validate:"required,min=1,dive,email"`
+	Cc                 []string `yaml:"cc,omitempty" json:"cc,omitempty" validate:"omitempty,dive,email"`
+	Bcc                []string `yaml:"bcc,omitempty" json:"bcc,omitempty" validate:"omitempty,dive,email"`
+	Attachment         string   `yaml:"attachment,omitempty" json:"attachment,omitempty" validate:"omitempty,oneof=csv json"`
 }
 
 // GetDefaults gets the default values
@@ -30,5 +35,6 @@ func (s *NotifMail) SetDefaults() {
 	s.Host = "localhost"
 	s.Port = 25
 	s.SSL = utl.NewFalse()
+	s.StartTLS = "opportunistic"
 	s.InsecureSkipVerify = utl.NewFalse()
 }
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+)
+
+// NotifMQTT holds mqtt notification configuration details
+type NotifMQTT struct {
+	Host               string         `yaml:"host,omitempty" json:"host,omitempty" validate:"required"`
+	Port               int            `yaml:"port,omitempty" json:"port,omitempty" validate:"required,min=1"`
+	ClientID           string         `yaml:"clientID,omitempty" json:"clientID,omitempty" validate:"required"`
+	Username           string         `yaml:"username,omitempty" json:"username,omitempty" validate:"omitempty"`
+	UsernameFile       string         `yaml:"usernameFile,omitempty" json:"usernameFile,omitempty" validate:"omitempty,file"`
+	Password           string         `yaml:"password,omitempty" json:"password,omitempty" validate:"omitempty"`
+	PasswordFile       string         `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty" validate:"omitempty,file"`
+	Topic              string         `yaml:"topic,omitempty" json:"topic,omitempty" validate:"required"`
+	EntriesTopic       string         `yaml:"entriesTopic,omitempty" json:"entriesTopic,omitempty" validate:"omitempty"`
+	QoS                int            `yaml:"qos,omitempty" json:"qos,omitempty" validate:"min=0,max=2"`
+	Retain             *bool          `yaml:"retain,omitempty" json:"retain,omitempty" validate:"required"`
+	TLS                *bool          `yaml:"tls,omitempty" json:"tls,omitempty" validate:"required"`
+	InsecureSkipVerify *bool          `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty" validate:"required"`
+	Timeout            *time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty" validate:"required"`
+}
+
+// GetDefaults gets the default values
+func (s *NotifMQTT) GetDefaults() *NotifMQTT {
+	n := &NotifMQTT{}
+	n.SetDefaults()
+	return n
+}
+
+// SetDefaults sets the default values
+func (s *NotifMQTT) SetDefaults() {
+	s.Port = 1883
+	s.ClientID = "ftpgrab"
+	s.Topic = "ftpgrab"
+	s.Retain = utl.NewFalse()
+	s.TLS = utl.NewFalse()
+	s.InsecureSkipVerify = utl.NewFalse()
+	s.Timeout = utl.NewDuration(10 * time.Second)
+}
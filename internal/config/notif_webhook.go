@@ -8,10 +8,16 @@ import (
 
 // NotifWebhook holds webhook notification configuration details
 type NotifWebhook struct {
-	Endpoint string            `yaml:"endpoint,omitempty" json:"endpoint,omitempty" validate:"required"`
-	Method   string            `yaml:"method,omitempty" json:"method,omitempty" validate:"required"`
-	Headers  map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" validate:"omitempty"`
-	Timeout  *time.Duration    `yaml:"timeout,omitempty" json:"timeout,omitempty" validate:"required"`
+	Endpoint      string            `yaml:"endpoint,omitempty" json:"endpoint,omitempty" validate:"required"`
+	Method        string            `yaml:"method,omitempty" json:"method,omitempty" validate:"required"`
+	Headers       map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" validate:"omitempty"`
+	Template      string            `yaml:"template,omitempty" json:"template,omitempty" validate:"omitempty"`
+	Secret        string            `yaml:"secret,omitempty" json:"secret,omitempty" validate:"omitempty"`
+	SecretFile    string            `yaml:"secretFile,omitempty" json:"secretFile,omitempty" validate:"omitempty,file"`
+	Timeout       *time.Duration    `yaml:"timeout,omitempty" json:"timeout,omitempty" validate:"required"`
+	Retry         int               `yaml:"retry,omitempty" json:"retry,omitempty" validate:"min=0"`
+	RetryDelay    *time.Duration    `yaml:"retryDelay,omitempty" json:"retryDelay,omitempty" validate:"required"`
+	RetryMaxDelay *time.Duration    `yaml:"retryMaxDelay,omitempty" json:"retryMaxDelay,omitempty" validate:"required"`
 }
 
 // GetDefaults gets the default values
@@ -25,4 +31,7 @@ func (s *NotifWebhook) GetDefaults() *NotifWebhook {
 func (s *NotifWebhook) SetDefaults() {
 	s.Method = "GET"
 	s.Timeout = utl.NewDuration(10 * time.Second)
+	s.Retry = 3
+	s.RetryDelay = utl.NewDuration(1 * time.Second)
+	s.RetryMaxDelay = utl.NewDuration(30 * time.Second)
 }
@@ -10,7 +10,17 @@ type Server struct {
 type ServerCommon struct {
 	Host    string
 	Port    int
-	Sources []string
+	Sources []Source
+}
+
+// Sources returns the sources of the configured server
+func (s *Server) Sources() []Source {
+	if s.FTP != nil {
+		return s.FTP.Sources
+	} else if s.SFTP != nil {
+		return s.SFTP.Sources
+	}
+	return nil
 }
 
 // GetDefaults gets the default values
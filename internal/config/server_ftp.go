@@ -1,28 +1,48 @@
 package config
 
 import (
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/pkg/errors"
 )
 
 // ServerFTP holds ftp server configuration
 type ServerFTP struct {
-	Host               string         `yaml:"host,omitempty" json:"host,omitempty" validate:"required"`
-	Port               int            `yaml:"port,omitempty" json:"port,omitempty" validate:"required,min=1"`
-	Username           string         `yaml:"username,omitempty" json:"username,omitempty"`
-	UsernameFile       string         `yaml:"usernameFile,omitempty" json:"usernameFile,omitempty" validate:"omitempty,file"`
-	Password           string         `yaml:"password,omitempty" json:"password,omitempty"`
-	PasswordFile       string         `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty" validate:"omitempty,file"`
-	Sources            []string       `yaml:"sources,omitempty" json:"sources,omitempty"`
-	Timeout            *time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
-	DisableUTF8        *bool          `yaml:"disableUTF8,omitempty" json:"disableUTF8,omitempty"`
-	DisableEPSV        *bool          `yaml:"disableEPSV,omitempty" json:"disableEPSV,omitempty"`
-	DisableMLSD        *bool          `yaml:"disableMLSD,omitempty" json:"disableMLSD,omitempty"`
-	EscapeRegexpMeta   *bool          `yaml:"escapeRegexpMeta,omitempty" json:"escapeRegexpMeta,omitempty"`
-	TLS                *bool          `yaml:"tls,omitempty" json:"tls,omitempty"`
-	InsecureSkipVerify *bool          `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty"`
-	LogTrace           *bool          `yaml:"logTrace,omitempty" json:"logTrace,omitempty"`
+	Host               string           `yaml:"host,omitempty" json:"host,omitempty" validate:"required"`
+	Port               int              `yaml:"port,omitempty" json:"port,omitempty" validate:"required,min=1"`
+	Username           string           `yaml:"username,omitempty" json:"username,omitempty"`
+	UsernameFile       string           `yaml:"usernameFile,omitempty" json:"usernameFile,omitempty" validate:"omitempty,file"`
+	Password           string           `yaml:"password,omitempty" json:"password,omitempty"`
+	PasswordFile       string           `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty" validate:"omitempty,file"`
+	Proxy              string           `yaml:"proxy,omitempty" json:"proxy,omitempty" validate:"omitempty,url"`
+	ProxyUsername      string           `yaml:"proxyUsername,omitempty" json:"proxyUsername,omitempty"`
+	ProxyUsernameFile  string           `yaml:"proxyUsernameFile,omitempty" json:"proxyUsernameFile,omitempty" validate:"omitempty,file"`
+	ProxyPassword      string           `yaml:"proxyPassword,omitempty" json:"proxyPassword,omitempty"`
+	ProxyPasswordFile  string           `yaml:"proxyPasswordFile,omitempty" json:"proxyPasswordFile,omitempty" validate:"omitempty,file"`
+	Sources            []Source         `yaml:"sources,omitempty" json:"sources,omitempty"`
+	Timeout            *time.Duration   `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Reconnect          *ServerReconnect `yaml:"reconnect,omitempty" json:"reconnect,omitempty" validate:"required"`
+	DisableUTF8        *bool            `yaml:"disableUTF8,omitempty" json:"disableUTF8,omitempty"`
+	DisableEPSV        *bool            `yaml:"disableEPSV,omitempty" json:"disableEPSV,omitempty"`
+	DisableMLSD        *bool            `yaml:"disableMLSD,omitempty" json:"disableMLSD,omitempty"`
+	ActiveMode         *bool            `yaml:"activeMode,omitempty" json:"activeMode,omitempty"`
+	ActiveListenAddr   string           `yaml:"activeListenAddr,omitempty" json:"activeListenAddr,omitempty" validate:"omitempty,ip"`
+	ActiveExternalIP   string           `yaml:"activeExternalIP,omitempty" json:"activeExternalIP,omitempty" validate:"omitempty,ip"`
+	ActivePortRange    string           `yaml:"activePortRange,omitempty" json:"activePortRange,omitempty"`
+	EscapeRegexpMeta   *bool            `yaml:"escapeRegexpMeta,omitempty" json:"escapeRegexpMeta,omitempty"`
+	Timezone           string           `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+	DetectOffset       *bool            `yaml:"detectOffset,omitempty" json:"detectOffset,omitempty"`
+	TLS                *bool            `yaml:"tls,omitempty" json:"tls,omitempty"`
+	TLSMode            string           `yaml:"tlsMode,omitempty" json:"tlsMode,omitempty" validate:"omitempty,oneof=explicit implicit"`
+	TLSMinVersion      string           `yaml:"tlsMinVersion,omitempty" json:"tlsMinVersion,omitempty" validate:"omitempty,oneof=1.0 1.1 1.2 1.3"`
+	CAFile             string           `yaml:"caFile,omitempty" json:"caFile,omitempty" validate:"omitempty,file"`
+	CertFile           string           `yaml:"certFile,omitempty" json:"certFile,omitempty" validate:"omitempty,file"`
+	KeyFile            string           `yaml:"keyFile,omitempty" json:"keyFile,omitempty" validate:"omitempty,file"`
+	InsecureSkipVerify *bool            `yaml:"insecureSkipVerify,omitempty" json:"insecureSkipVerify,omitempty"`
+	LogTrace           *bool            `yaml:"logTrace,omitempty" json:"logTrace,omitempty"`
 }
 
 // GetDefaults gets the default values
@@ -35,13 +55,60 @@ func (s *ServerFTP) GetDefaults() *ServerFTP {
 // SetDefaults sets the default values
 func (s *ServerFTP) SetDefaults() {
 	s.Port = 21
-	s.Sources = []string{}
+	s.Sources = []Source{}
 	s.Timeout = utl.NewDuration(5 * time.Second)
+	s.Reconnect = (&ServerReconnect{}).GetDefaults()
 	s.DisableUTF8 = utl.NewFalse()
 	s.DisableEPSV = utl.NewFalse()
 	s.DisableMLSD = utl.NewFalse()
+	s.ActiveMode = utl.NewFalse()
 	s.EscapeRegexpMeta = utl.NewFalse()
+	s.Timezone = "UTC"
+	s.DetectOffset = utl.NewTrue()
 	s.TLS = utl.NewFalse()
+	s.TLSMode = "implicit"
+	s.TLSMinVersion = "1.2"
 	s.InsecureSkipVerify = utl.NewFalse()
 	s.LogTrace = utl.NewFalse()
 }
+
+// ActivePorts returns the port range to listen on in active mode. Zero
+// values mean any available port.
+func (s *ServerFTP) ActivePorts() (int, int, error) {
+	if len(s.ActivePortRange) == 0 {
+		return 0, 0, nil
+	}
+	bounds := strings.SplitN(s.ActivePortRange, "-", 2)
+	if len(bounds) == 1 {
+		bounds = append(bounds, bounds[0])
+	}
+	min, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return 0, 0, errors.Errorf("Invalid FTP active port range %s", s.ActivePortRange)
+	}
+	max, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil {
+		return 0, 0, errors.Errorf("Invalid FTP active port range %s", s.ActivePortRange)
+	}
+	if min < 1 || max > 65535 || min > max {
+		return 0, 0, errors.Errorf("Invalid FTP active port range %s", s.ActivePortRange)
+	}
+	return min, max, nil
+}
+
+// validate checks timezone, TLS, active mode and reconnection settings
+func (s *ServerFTP) validate() error {
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return errors.Wrapf(err, "Invalid FTP timezone %s", s.Timezone)
+	}
+	if (len(s.CertFile) > 0) != (len(s.KeyFile) > 0) {
+		return errors.New("FTP client certificate requires both a cert and a key file")
+	}
+	if _, _, err := s.ActivePorts(); err != nil {
+		return err
+	}
+	if *s.ActiveMode && len(s.Proxy) > 0 {
+		return errors.New("FTP active mode cannot be used through a proxy")
+	}
+	return s.Reconnect.validate()
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/pkg/errors"
+)
+
+// ServerReconnect holds settings to reconnect to a server once the
+// connection is lost
+type ServerReconnect struct {
+	Attempts int            `yaml:"attempts,omitempty" json:"attempts,omitempty" validate:"min=0"`
+	Delay    *time.Duration `yaml:"delay,omitempty" json:"delay,omitempty" validate:"required"`
+	MaxDelay *time.Duration `yaml:"maxDelay,omitempty" json:"maxDelay,omitempty" validate:"required"`
+}
+
+// GetDefaults gets the default values
+func (s *ServerReconnect) GetDefaults() *ServerReconnect {
+	n := &ServerReconnect{}
+	n.SetDefaults()
+	return n
+}
+
+// SetDefaults sets the default values
+func (s *ServerReconnect) SetDefaults() {
+	s.Attempts = 5
+	s.Delay = utl.NewDuration(1 * time.Second)
+	s.MaxDelay = utl.NewDuration(30 * time.Second)
+}
+
+// validate checks the reconnection delays
+func (s *ServerReconnect) validate() error {
+	if *s.MaxDelay < *s.Delay {
+		return errors.New("Max reconnect delay cannot be lower than initial delay")
+	}
+	return nil
+}
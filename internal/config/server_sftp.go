@@ -4,22 +4,34 @@ import (
 	"time"
 
 	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/pkg/errors"
 )
 
 // ServerSFTP holds sftp server configuration
 type ServerSFTP struct {
-	Host              string         `yaml:"host,omitempty" json:"host,omitempty" validate:"required"`
-	Port              int            `yaml:"port,omitempty" json:"port,omitempty" validate:"required,min=1"`
-	Username          string         `yaml:"username,omitempty" json:"username,omitempty"`
-	UsernameFile      string         `yaml:"usernameFile,omitempty" json:"usernameFile,omitempty" validate:"omitempty,file"`
-	Password          string         `yaml:"password,omitempty" json:"password,omitempty"`
-	PasswordFile      string         `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty" validate:"omitempty,file"`
-	KeyFile           string         `yaml:"keyFile,omitempty" json:"keyFile,omitempty" validate:"omitempty,file"`
-	KeyPassphrase     string         `yaml:"keyPassphrase,omitempty" json:"keyPassphrase,omitempty"`
-	KeyPassphraseFile string         `yaml:"keyPassphraseFile,omitempty" json:"keyPassphraseFile,omitempty" validate:"omitempty,file"`
-	Sources           []string       `yaml:"sources,omitempty" json:"sources,omitempty"`
-	Timeout           *time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
-	MaxPacketSize     int            `yaml:"maxPacketSize,omitempty" json:"maxPacketSize,omitempty"`
+	Host              string           `yaml:"host,omitempty" json:"host,omitempty" validate:"required"`
+	Port              int              `yaml:"port,omitempty" json:"port,omitempty" validate:"required,min=1"`
+	Username          string           `yaml:"username,omitempty" json:"username,omitempty"`
+	UsernameFile      string           `yaml:"usernameFile,omitempty" json:"usernameFile,omitempty" validate:"omitempty,file"`
+	Password          string           `yaml:"password,omitempty" json:"password,omitempty"`
+	PasswordFile      string           `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty" validate:"omitempty,file"`
+	KeyFile           string           `yaml:"keyFile,omitempty" json:"keyFile,omitempty" validate:"omitempty,file"`
+	KeyPassphrase     string           `yaml:"keyPassphrase,omitempty" json:"keyPassphrase,omitempty"`
+	KeyPassphraseFile string           `yaml:"keyPassphraseFile,omitempty" json:"keyPassphraseFile,omitempty" validate:"omitempty,file"`
+	CertFile          string           `yaml:"certFile,omitempty" json:"certFile,omitempty" validate:"omitempty,file"`
+	AuthMethods       []string         `yaml:"authMethods,omitempty" json:"authMethods,omitempty" validate:"omitempty,dive,oneof=agent publickey password keyboard-interactive"`
+	HostKey           string           `yaml:"hostKey,omitempty" json:"hostKey,omitempty"`
+	KnownHostsFile    string           `yaml:"knownHostsFile,omitempty" json:"knownHostsFile,omitempty" validate:"omitempty,file"`
+	ProxyJump         []ServerSFTPJump `yaml:"proxyJump,omitempty" json:"proxyJump,omitempty" validate:"omitempty,dive"`
+	Proxy             string           `yaml:"proxy,omitempty" json:"proxy,omitempty" validate:"omitempty,url"`
+	ProxyUsername     string           `yaml:"proxyUsername,omitempty" json:"proxyUsername,omitempty"`
+	ProxyUsernameFile string           `yaml:"proxyUsernameFile,omitempty" json:"proxyUsernameFile,omitempty" validate:"omitempty,file"`
+	ProxyPassword     string           `yaml:"proxyPassword,omitempty" json:"proxyPassword,omitempty"`
+	ProxyPasswordFile string           `yaml:"proxyPasswordFile,omitempty" json:"proxyPasswordFile,omitempty" validate:"omitempty,file"`
+	Sources           []Source         `yaml:"sources,omitempty" json:"sources,omitempty"`
+	Timeout           *time.Duration   `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Reconnect         *ServerReconnect `yaml:"reconnect,omitempty" json:"reconnect,omitempty" validate:"required"`
+	MaxPacketSize     int              `yaml:"maxPacketSize,omitempty" json:"maxPacketSize,omitempty"`
 }
 
 // GetDefaults gets the default values
@@ -32,7 +44,40 @@ func (s *ServerSFTP) GetDefaults() *ServerSFTP {
 // SetDefaults sets the default values
 func (s *ServerSFTP) SetDefaults() {
 	s.Port = 22
-	s.Sources = []string{}
+	s.Sources = []Source{}
 	s.Timeout = utl.NewDuration(30 * time.Second)
+	s.Reconnect = (&ServerReconnect{}).GetDefaults()
 	s.MaxPacketSize = 32768
 }
+
+// validate checks authentication, host key and reconnection settings
+func (s *ServerSFTP) validate() error {
+	if len(s.HostKey) > 0 && len(s.KnownHostsFile) > 0 {
+		return errors.New("SFTP host key and known hosts file are mutually exclusive")
+	}
+	if len(s.CertFile) > 0 && len(s.KeyFile) == 0 {
+		return errors.New("SFTP certificate requires a key file")
+	}
+	for _, method := range s.AuthMethods {
+		switch method {
+		case "publickey":
+			if len(s.KeyFile) == 0 {
+				return errors.New("SFTP publickey authentication requires a key file")
+			}
+		case "password", "keyboard-interactive":
+			if len(s.Password) == 0 && len(s.PasswordFile) == 0 {
+				return errors.Errorf("SFTP %s authentication requires a password", method)
+			}
+		}
+	}
+	for _, jump := range s.ProxyJump {
+		if err := jump.validate(); err != nil {
+			return err
+		}
+	}
+	// Jump hosts have no reconnection settings
+	if s.Reconnect != nil {
+		return s.Reconnect.validate()
+	}
+	return nil
+}
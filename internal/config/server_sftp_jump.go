@@ -0,0 +1,61 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ServerSFTPJump holds jump host configuration used to reach a sftp server
+type ServerSFTPJump struct {
+	Host              string   `yaml:"host,omitempty" json:"host,omitempty" validate:"required"`
+	Port              int      `yaml:"port,omitempty" json:"port,omitempty" validate:"required,min=1"`
+	Username          string   `yaml:"username,omitempty" json:"username,omitempty"`
+	UsernameFile      string   `yaml:"usernameFile,omitempty" json:"usernameFile,omitempty" validate:"omitempty,file"`
+	Password          string   `yaml:"password,omitempty" json:"password,omitempty"`
+	PasswordFile      string   `yaml:"passwordFile,omitempty" json:"passwordFile,omitempty" validate:"omitempty,file"`
+	KeyFile           string   `yaml:"keyFile,omitempty" json:"keyFile,omitempty" validate:"omitempty,file"`
+	KeyPassphrase     string   `yaml:"keyPassphrase,omitempty" json:"keyPassphrase,omitempty"`
+	KeyPassphraseFile string   `yaml:"keyPassphraseFile,omitempty" json:"keyPassphraseFile,omitempty" validate:"omitempty,file"`
+	CertFile          string   `yaml:"certFile,omitempty" json:"certFile,omitempty" validate:"omitempty,file"`
+	AuthMethods       []string `yaml:"authMethods,omitempty" json:"authMethods,omitempty" validate:"omitempty,dive,oneof=agent publickey password keyboard-interactive"`
+	HostKey           string   `yaml:"hostKey,omitempty" json:"hostKey,omitempty"`
+	KnownHostsFile    string   `yaml:"knownHostsFile,omitempty" json:"knownHostsFile,omitempty" validate:"omitempty,file"`
+}
+
+// GetDefaults gets the default values
+func (s *ServerSFTPJump) GetDefaults() *ServerSFTPJump {
+	n := &ServerSFTPJump{}
+	n.SetDefaults()
+	return n
+}
+
+// SetDefaults sets the default values
+func (s *ServerSFTPJump) SetDefaults() {
+	s.Port = 22
+}
+
+// Server returns the connection and authentication settings of the jump
+// host as a sftp server configuration
+func (s *ServerSFTPJump) Server() *ServerSFTP {
+	return &ServerSFTP{
+		Host:              s.Host,
+		Port:              s.Port,
+		Username:          s.Username,
+		UsernameFile:      s.UsernameFile,
+		Password:          s.Password,
+		PasswordFile:      s.PasswordFile,
+		KeyFile:           s.KeyFile,
+		KeyPassphrase:     s.KeyPassphrase,
+		KeyPassphraseFile: s.KeyPassphraseFile,
+		CertFile:          s.CertFile,
+		AuthMethods:       s.AuthMethods,
+		HostKey:           s.HostKey,
+		KnownHostsFile:    s.KnownHostsFile,
+	}
+}
+
+func (s *ServerSFTPJump) validate() error {
+	if err := s.Server().validate(); err != nil {
+		return errors.Wrapf(err, "Jump host %s", s.Host)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Source holds a server source path and optional download settings that
+// override the global ones
+type Source struct {
+	Path          string       `yaml:"path,omitempty" json:"path,omitempty" validate:"required"`
+	Output        string       `yaml:"output,omitempty" json:"output,omitempty"`
+	UID           *int         `yaml:"uid,omitempty" json:"uid,omitempty"`
+	GID           *int         `yaml:"gid,omitempty" json:"gid,omitempty"`
+	ChmodFile     *os.FileMode `yaml:"chmodFile,omitempty" json:"chmodFile,omitempty"`
+	ChmodDir      *os.FileMode `yaml:"chmodDir,omitempty" json:"chmodDir,omitempty"`
+	Include       []string     `yaml:"include,omitempty" json:"include,omitempty"`
+	Exclude       []string     `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+	IncludePath   []string     `yaml:"includePath,omitempty" json:"includePath,omitempty"`
+	ExcludePath   []string     `yaml:"excludePath,omitempty" json:"excludePath,omitempty"`
+	ExcludeDir    []string     `yaml:"excludeDir,omitempty" json:"excludeDir,omitempty"`
+	MinSize       string       `yaml:"minSize,omitempty" json:"minSize,omitempty"`
+	MaxSize       string       `yaml:"maxSize,omitempty" json:"maxSize,omitempty"`
+	MinAge        string       `yaml:"minAge,omitempty" json:"minAge,omitempty"`
+	MaxAge        string       `yaml:"maxAge,omitempty" json:"maxAge,omitempty"`
+	Since         string       `yaml:"since,omitempty" json:"since,omitempty"`
+	CreateBaseDir *bool        `yaml:"createBaseDir,omitempty" json:"createBaseDir,omitempty"`
+	PostAction    string       `yaml:"postAction,omitempty" json:"postAction,omitempty" validate:"omitempty,oneof=none delete"`
+}
+
+// Override returns a copy of download settings overridden by the ones
+// defined for this source
+func (s *Source) Override(dl *Download) (*Download, error) {
+	n := *dl
+	if len(s.Output) > 0 {
+		n.Output = s.Output
+	}
+	if s.UID != nil {
+		n.UID = *s.UID
+	}
+	if s.GID != nil {
+		n.GID = *s.GID
+	}
+	if s.ChmodFile != nil {
+		n.ChmodFile = *s.ChmodFile
+	}
+	if s.ChmodDir != nil {
+		n.ChmodDir = *s.ChmodDir
+	}
+	if len(s.Include) > 0 {
+		n.Include = s.Include
+	}
+	if len(s.Exclude) > 0 {
+		n.Exclude = s.Exclude
+	}
+	if len(s.IncludePath) > 0 {
+		n.IncludePath = s.IncludePath
+	}
+	if len(s.ExcludePath) > 0 {
+		n.ExcludePath = s.ExcludePath
+	}
+	if len(s.ExcludeDir) > 0 {
+		n.ExcludeDir = s.ExcludeDir
+	}
+	if len(s.MinSize) > 0 {
+		n.MinSize = s.MinSize
+	}
+	if len(s.MaxSize) > 0 {
+		n.MaxSize = s.MaxSize
+	}
+	if len(s.MinAge) > 0 {
+		n.MinAge = s.MinAge
+	}
+	if len(s.MaxAge) > 0 {
+		n.MaxAge = s.MaxAge
+	}
+	if len(s.Since) > 0 {
+		n.Since = s.Since
+	}
+	if s.CreateBaseDir != nil {
+		n.CreateBaseDir = s.CreateBaseDir
+	}
+	if len(s.PostAction) > 0 {
+		n.PostAction = s.PostAction
+	}
+	if err := n.validate(); err != nil {
+		return nil, errors.Wrapf(err, "Invalid settings for source %s", s.Path)
+	}
+	return &n, nil
+}
+
+// sourcesFromList returns sources from a comma separated list of paths
+func sourcesFromList(list string) []Source {
+	var sources []Source
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); len(p) > 0 {
+			sources = append(sources, Source{Path: p})
+		}
+	}
+	return sources
+}
+
+// normalizeSources rewrites sources of a YAML configuration defined as
+// plain paths to their object form so both can be mixed
+func normalizeSources(content []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return content, nil
+	}
+
+	server := mappingValue(doc.Content[0], "server")
+	for _, name := range []string{"ftp", "sftp"} {
+		sources := mappingValue(mappingValue(server, name), "sources")
+		if sources == nil {
+			continue
+		}
+		if sources.Kind == yaml.ScalarNode {
+			item := *sources
+			*sources = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{&item}}
+		}
+		for i, item := range sources.Content {
+			if item.Kind != yaml.ScalarNode {
+				continue
+			}
+			sources.Content[i] = &yaml.Node{
+				Kind: yaml.MappingNode,
+				Tag:  "!!map",
+				Content: []*yaml.Node{
+					{Kind: yaml.ScalarNode, Tag: "!!str", Value: "path"},
+					item,
+				},
+			}
+		}
+	}
+
+	return yaml.Marshal(&doc)
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltStore stores buckets in a bbolt file. The file is locked while open,
+// so it cannot be shared between instances. Buckets may be missing from
+// files opened read-only.
+type boltStore struct {
+	db *bolt.DB
+}
+
+func newBolt(path string, readOnly bool) (*boltStore, error) {
+	// bolt creates missing files even when opened read-only
+	if readOnly {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{
+		Timeout:  10 * time.Second,
+		ReadOnly: readOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if readOnly {
+		return &boltStore{db: db}, nil
+	}
+
+	if err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Has(bucket string, key string) (bool, error) {
+	value, err := s.Get(bucket, key)
+	return value != nil, err
+}
+
+func (s *boltStore) Get(bucket string, key string) (value []byte, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			if v := b.Get([]byte(key)); v != nil {
+				value = append([]byte{}, v...)
+			}
+		}
+		return nil
+	})
+	return value, err
+}
+
+func (s *boltStore) Put(bucket string, key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Put([]byte(key), value)
+	})
+}
+
+func (s *boltStore) Swap(bucket string, key string, old []byte, new []byte) (swapped bool, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		current := b.Get([]byte(key))
+		if (current == nil) != (old == nil) || !bytes.Equal(current, old) {
+			return nil
+		}
+		swapped = true
+		if new == nil {
+			return b.Delete([]byte(key))
+		}
+		return b.Put([]byte(key), new)
+	})
+	return swapped, err
+}
+
+func (s *boltStore) Delete(bucket string, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Delete([]byte(key))
+	})
+}
+
+func (s *boltStore) List(bucket string) (keys []string, err error) {
+	err = s.Iterate(bucket, func(key string, _ []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
+
+func (s *boltStore) Iterate(bucket string, fn func(key string, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
+
+func (s *boltStore) Count(bucket string) (count int, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket([]byte(bucket)); b != nil {
+			count = b.Stats().KeyN
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Backup copies the database file next to it
+func (s *boltStore) Backup(name string) (string, error) {
+	dest := fmt.Sprintf("%s.%s", s.db.Path(), name)
+	return dest, s.db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(dest, 0600)
+	})
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
@@ -11,14 +11,12 @@ import (
 	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
-	bolt "go.etcd.io/bbolt"
 )
 
 // Client represents an active db object
 type Client struct {
-	*bolt.DB
-	cfg    *config.Db
-	bucket string
+	store Store
+	cfg   *config.Db
 }
 
 type entry struct {
@@ -29,45 +27,28 @@ type entry struct {
 
 // New creates new db instance
 func New(cfg *config.Db) (c *Client, err error) {
-	var db *bolt.DB
-	var bucket = "ftpgrab"
-
-	if cfg == nil || len(cfg.Path) == 0 {
-		return &Client{
-			cfg:    cfg,
-			bucket: bucket,
-		}, nil
+	if !cfg.Enabled() {
+		return &Client{cfg: cfg}, nil
 	}
 
-	db, err = bolt.Open(cfg.Path, 0600, &bolt.Options{
-		Timeout: 10 * time.Second,
-	})
+	store, err := open(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(bucket))
-		return err
-	}); err != nil {
-		return nil, err
-	}
-
-	if err = db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(bucket))
-		stats := b.Stats()
-		log.Debug().Msgf("%d entries found in database", stats.KeyN)
-		return nil
-	}); err != nil {
+	count, err := store.Count(hashBucket)
+	if err != nil {
+		_ = store.Close()
 		return nil, errors.Wrap(err, "Cannot count entries in database")
 	}
+	log.Debug().Msgf("%d entries found in %s database", count, cfg.Type)
 
-	return &Client{db, cfg, bucket}, nil
+	return &Client{store: store, cfg: cfg}, nil
 }
 
 // Enabled verifies if db is enabled
 func (c *Client) Enabled() bool {
-	return c.cfg != nil && len(c.cfg.Path) > 0
+	return c.store != nil
 }
 
 // Close closes db connection
@@ -75,7 +56,7 @@ func (c *Client) Close() error {
 	if !c.Enabled() {
 		return nil
 	}
-	return c.DB.Close()
+	return c.store.Close()
 }
 
 // HasHash checks if hash is present for a file in db
@@ -84,17 +65,11 @@ func (c *Client) HasHash(base string, source string, file os.FileInfo) bool {
 		return false
 	}
 
-	exists := false
 	filename := strings.TrimPrefix(path.Join(source, file.Name()), base)
-	hash := utl.Hash(filename)
-
-	_ = c.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(c.bucket))
-		if entryBytes := b.Get([]byte(hash)); entryBytes != nil {
-			exists = true
-		}
-		return nil
-	})
+	exists, err := c.store.Has(hashBucket, utl.Hash(filename))
+	if err != nil {
+		log.Warn().Err(err).Msgf("Cannot check hash of %s in database", filename)
+	}
 
 	return exists
 }
@@ -106,18 +81,40 @@ func (c *Client) PutHash(base string, source string, file os.FileInfo) error {
 	}
 
 	filename := strings.TrimPrefix(path.Join(source, file.Name()), base)
-	hash := utl.Hash(filename)
-
 	entryBytes, _ := json.Marshal(entry{
 		File: filename,
 		Size: file.Size(),
 		Date: time.Now(),
 	})
 
-	err := c.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(c.bucket))
-		return b.Put([]byte(hash), entryBytes)
-	})
+	return c.store.Put(hashBucket, utl.Hash(filename), entryBytes)
+}
+
+// GetWatermark returns the newest modification time stored for a source
+func (c *Client) GetWatermark(base string) (time.Time, error) {
+	var watermark time.Time
+	if !c.Enabled() {
+		return watermark, nil
+	}
+
+	value, err := c.store.Get(watermarkBucket, base)
+	if err != nil || value == nil {
+		return watermark, err
+	}
+
+	return watermark, watermark.UnmarshalText(value)
+}
+
+// PutWatermark stores the newest modification time seen for a source
+func (c *Client) PutWatermark(base string, watermark time.Time) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	value, err := watermark.MarshalText()
+	if err != nil {
+		return err
+	}
 
-	return err
+	return c.store.Put(watermarkBucket, base, value)
 }
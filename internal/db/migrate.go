@@ -0,0 +1,78 @@
+package db
+
+import (
+	"path/filepath"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Migrate copies all entries from a database to another one and returns
+// the number of entries copied. Existing entries are overwritten. The source
+// database is opened read-only and its schema is not upgraded, entries are
+// upgraded in the destination database instead.
+func Migrate(from *config.Db, to *config.Db) (int, error) {
+	if !from.Enabled() || !to.Enabled() {
+		return 0, errors.New("Source and destination databases must be enabled")
+	}
+	if from.Type != "redis" && from.Type == to.Type {
+		fromPath, _ := filepath.Abs(from.Path)
+		toPath, _ := filepath.Abs(to.Path)
+		if fromPath == toPath {
+			return 0, errors.New("Source and destination databases are the same")
+		}
+	}
+
+	src, err := openStore(from, true)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Cannot open %s source database", from.Type)
+	}
+	defer src.Close()
+	version, err := readVersion(src)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Cannot migrate from %s source database", from.Type)
+	}
+
+	dst, err := openStore(to, false)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Cannot open %s destination database", to.Type)
+	}
+	defer dst.Close()
+	dstVersion, err := readVersion(dst)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Cannot migrate to %s destination database", to.Type)
+	}
+	dstEmpty, err := isEmpty(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int
+	for _, bucket := range buckets {
+		if bucket == metaBucket {
+			continue
+		}
+		var count int
+		if err := src.Iterate(bucket, func(key string, value []byte) error {
+			count++
+			return dst.Put(bucket, key, value)
+		}); err != nil {
+			return total, errors.Wrapf(err, "Cannot migrate %s bucket", bucket)
+		}
+		log.Debug().Msgf("%d entries migrated from %s bucket", count, bucket)
+		total += count
+	}
+
+	// Entries copied have the schema of the source database
+	if total > 0 && (dstEmpty || version < dstVersion) {
+		if err = setVersion(dst, version); err != nil {
+			return total, err
+		}
+	}
+	if err = upgrade(dst); err != nil {
+		return total, err
+	}
+
+	return total, nil
+}
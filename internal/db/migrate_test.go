@@ -0,0 +1,89 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	dbs := testDbs()
+	cases := [][2]string{
+		{"bolt", "sqlite"},
+		{"sqlite", "bolt"},
+		{"bolt", "redis"},
+		{"redis", "sqlite"},
+	}
+	for _, tt := range cases {
+		tt := tt
+		t.Run(fmt.Sprintf("%s to %s", tt[0], tt[1]), func(t *testing.T) {
+			from, to := dbs[tt[0]](t), dbs[tt[1]](t)
+
+			// Source database created before schemas were versioned
+			src, err := openStore(from, false)
+			require.NoError(t, err)
+			for i := 0; i < 10; i++ {
+				require.NoError(t, src.Put(hashBucket, fmt.Sprintf("hash%d", i), []byte(fmt.Sprintf("entry%d", i))))
+			}
+			require.NoError(t, src.Put(watermarkBucket, "/src", []byte("watermark")))
+			require.NoError(t, src.Close())
+
+			count, err := Migrate(from, to)
+			require.NoError(t, err)
+			assert.Equal(t, 11, count)
+
+			dst, err := openStore(to, false)
+			require.NoError(t, err)
+			defer dst.Close()
+			for i := 0; i < 10; i++ {
+				value, err := dst.Get(hashBucket, fmt.Sprintf("hash%d", i))
+				require.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("entry%d", i), string(value))
+			}
+			value, err := dst.Get(watermarkBucket, "/src")
+			require.NoError(t, err)
+			assert.Equal(t, "watermark", string(value))
+			version, err := getVersion(dst)
+			require.NoError(t, err)
+			assert.Equal(t, schemaVersion(), version)
+
+			// Source database is left as is
+			src, err = openStore(from, true)
+			require.NoError(t, err)
+			defer src.Close()
+			version, err = getVersion(src)
+			require.NoError(t, err)
+			assert.Equal(t, 0, version)
+			if from.Type != "redis" {
+				backups, err := filepath.Glob(from.Path + ".backup-*")
+				require.NoError(t, err)
+				assert.Empty(t, backups)
+			}
+		})
+	}
+}
+
+func TestMigrateMissingSource(t *testing.T) {
+	for _, dbType := range []string{"bolt", "sqlite"} {
+		dbType := dbType
+		t.Run(dbType, func(t *testing.T) {
+			from := &config.Db{Type: dbType, Path: filepath.Join(t.TempDir(), "missing.db")}
+			to := &config.Db{Type: "bolt", Path: filepath.Join(t.TempDir(), "ftpgrab.db")}
+			_, err := Migrate(from, to)
+			require.Error(t, err)
+			_, err = os.Stat(from.Path)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestMigrateSame(t *testing.T) {
+	cfg := &config.Db{Type: "bolt", Path: filepath.Join(t.TempDir(), "ftpgrab.db")}
+	_, err := Migrate(cfg, cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// swapScript replaces the value of a hash field if it is still the expected
+// one. Arguments are the field, whether it is expected to exist, the
+// expected value, whether it has to be set and the new value.
+var swapScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if ARGV[2] == '0' then
+	if current then return 0 end
+elseif current ~= ARGV[3] then
+	return 0
+end
+if ARGV[4] == '0' then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+else
+	redis.call('HSET', KEYS[1], ARGV[1], ARGV[5])
+end
+return 1
+`)
+
+// redisStore stores each bucket in a redis hash so several instances can
+// share the same state
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func newRedis(cfg *config.DbRedis) (*redisStore, error) {
+	username, err := utl.GetSecret(cfg.Username, cfg.UsernameFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot retrieve username secret for redis database")
+	}
+	password, err := utl.GetSecret(cfg.Password, cfg.PasswordFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot retrieve password secret for redis database")
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Username: username,
+		Password: password,
+		DB:       cfg.DB,
+	})
+	if err = client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close()
+		return nil, errors.Wrapf(err, "Cannot connect to redis at %s", cfg.Addr)
+	}
+
+	return &redisStore{client: client, prefix: cfg.KeyPrefix}, nil
+}
+
+func (s *redisStore) key(bucket string) string {
+	return fmt.Sprintf("%s:%s", s.prefix, bucket)
+}
+
+func (s *redisStore) Has(bucket string, key string) (bool, error) {
+	return s.client.HExists(context.Background(), s.key(bucket), key).Result()
+}
+
+func (s *redisStore) Get(bucket string, key string) ([]byte, error) {
+	value, err := s.client.HGet(context.Background(), s.key(bucket), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (s *redisStore) Put(bucket string, key string, value []byte) error {
+	return s.client.HSet(context.Background(), s.key(bucket), key, value).Err()
+}
+
+func (s *redisStore) Swap(bucket string, key string, old []byte, new []byte) (bool, error) {
+	flag := func(value []byte) string {
+		if value == nil {
+			return "0"
+		}
+		return "1"
+	}
+	swapped, err := swapScript.Run(context.Background(), s.client, []string{s.key(bucket)},
+		key, flag(old), old, flag(new), new).Int()
+	return swapped == 1, err
+}
+
+func (s *redisStore) Delete(bucket string, key string) error {
+	return s.client.HDel(context.Background(), s.key(bucket), key).Err()
+}
+
+func (s *redisStore) List(bucket string) ([]string, error) {
+	return s.client.HKeys(context.Background(), s.key(bucket)).Result()
+}
+
+func (s *redisStore) Iterate(bucket string, fn func(key string, value []byte) error) error {
+	iter := s.client.HScan(context.Background(), s.key(bucket), 0, "", 0).Iterator()
+	for iter.Next(context.Background()) {
+		key := iter.Val()
+		if !iter.Next(context.Background()) {
+			break
+		}
+		if err := fn(key, []byte(iter.Val())); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func (s *redisStore) Count(bucket string) (int, error) {
+	count, err := s.client.HLen(context.Background(), s.key(bucket)).Result()
+	return int(count), err
+}
+
+// Backup copies each bucket to a hash named <prefix>:<name>:<bucket>
+func (s *redisStore) Backup(name string) (string, error) {
+	dest := fmt.Sprintf("%s:%s", s.prefix, name)
+	for _, bucket := range buckets {
+		backupKey := fmt.Sprintf("%s:%s", dest, bucket)
+		if err := s.Iterate(bucket, func(key string, value []byte) error {
+			return s.client.HSet(context.Background(), backupKey, key, value).Err()
+		}); err != nil {
+			return dest, err
+		}
+	}
+	return dest, nil
+}
+
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
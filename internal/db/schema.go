@@ -0,0 +1,224 @@
+package db
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// schemaVersionKey is the key of the schema version in the meta bucket
+	schemaVersionKey = "schemaVersion"
+
+	// upgradeLockKey is the key of the lock held in the meta bucket while
+	// upgrading the schema, so instances sharing a database do not upgrade
+	// it concurrently
+	upgradeLockKey = "upgradeLock"
+
+	// upgradeLockTTL is the time after which a lock left by an instance
+	// that stopped while upgrading is taken over
+	upgradeLockTTL = 10 * time.Minute
+)
+
+// upgradeLockWait is how long to wait for another instance to upgrade the
+// schema
+var upgradeLockWait = time.Minute
+
+// migration upgrades the schema of a database to version. Migrations
+// without up function only record the version.
+type migration struct {
+	version int
+	desc    string
+	up      func(store Store) error
+}
+
+// migrations lists schema migrations in order. Databases created before
+// schemas were versioned are at version 0.
+var migrations = []migration{
+	{
+		version: 1,
+		desc:    "Record schema version",
+	},
+}
+
+// schemaVersion is the schema version of databases created by ftpgrab
+func schemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// open opens the storage backend of a database configuration and upgrades
+// its schema if needed
+func open(cfg *config.Db) (Store, error) {
+	store, err := openStore(cfg, false)
+	if err != nil {
+		return nil, err
+	}
+	if err = upgrade(store); err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Upgrade opens a database to upgrade its schema and returns its schema
+// version
+func Upgrade(cfg *config.Db) (int, error) {
+	if !cfg.Enabled() {
+		return 0, errors.New("Database is not enabled")
+	}
+	store, err := open(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer store.Close()
+	return getVersion(store)
+}
+
+// upgrade runs pending migrations. A backup of the database is taken before
+// migrating unless pending migrations only record the version. New
+// databases are marked with the latest schema version.
+func upgrade(store Store) error {
+	latest := schemaVersion()
+	if version, err := readVersion(store); err != nil || version == latest {
+		return err
+	}
+
+	unlock, err := lockUpgrade(store)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another instance may have upgraded the schema while waiting
+	version, err := readVersion(store)
+	if err != nil || version == latest {
+		return err
+	}
+
+	if empty, err := isEmpty(store); err != nil {
+		return err
+	} else if empty {
+		log.Debug().Msgf("Initializing database schema version %d", latest)
+		return setVersion(store, latest)
+	} else if !changesData(version) {
+		log.Debug().Msgf("Recording database schema version %d", latest)
+		return setVersion(store, latest)
+	}
+
+	backup, err := store.Backup(fmt.Sprintf("backup-v%d-%s", version, time.Now().Format("20060102150405")))
+	if err != nil {
+		return errors.Wrap(err, "Cannot back up database before migrating")
+	}
+	log.Info().Msgf("Database backed up to %s", backup)
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		log.Info().Msgf("Migrating database schema to version %d: %s", m.version, m.desc)
+		if m.up != nil {
+			if err := m.up(store); err != nil {
+				return errors.Wrapf(err, "Cannot migrate database schema to version %d", m.version)
+			}
+		}
+		if err := setVersion(store, m.version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changesData checks if a migration pending from version changes data
+func changesData(version int) bool {
+	for _, m := range migrations {
+		if m.version > version && m.up != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// readVersion returns the schema version of a database, which must not be
+// newer than the supported one
+func readVersion(store Store) (int, error) {
+	version, err := getVersion(store)
+	if err != nil {
+		return 0, errors.Wrap(err, "Cannot read database schema version")
+	}
+	if latest := schemaVersion(); version > latest {
+		return 0, errors.Errorf("Database schema version %d is newer than supported version %d", version, latest)
+	}
+	return version, nil
+}
+
+// lockUpgrade takes the upgrade lock of a database, waiting for another
+// instance to release it, and returns a function releasing it
+func lockUpgrade(store Store) (func(), error) {
+	token := []byte(fmt.Sprintf("%d-%d", time.Now().Add(upgradeLockTTL).UnixNano(), rand.Int63()))
+	deadline := time.Now().Add(upgradeLockWait)
+	for {
+		current, err := store.Get(metaBucket, upgradeLockKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "Cannot read database upgrade lock")
+		}
+		if current == nil || lockExpired(current) {
+			locked, err := store.Swap(metaBucket, upgradeLockKey, current, token)
+			if err != nil {
+				return nil, errors.Wrap(err, "Cannot take database upgrade lock")
+			} else if locked {
+				return func() {
+					if _, err := store.Swap(metaBucket, upgradeLockKey, token, nil); err != nil {
+						log.Warn().Err(err).Msg("Cannot release database upgrade lock")
+					}
+				}, nil
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("Database schema is being upgraded by another instance")
+		}
+		log.Debug().Msg("Waiting for another instance to upgrade database schema")
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// lockExpired checks if the expiration time of a lock token has passed
+func lockExpired(token []byte) bool {
+	expires, err := strconv.ParseInt(strings.SplitN(string(token), "-", 2)[0], 10, 64)
+	return err != nil || time.Now().UnixNano() > expires
+}
+
+func getVersion(store Store) (int, error) {
+	value, err := store.Get(metaBucket, schemaVersionKey)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(value))
+}
+
+func setVersion(store Store, version int) error {
+	return store.Put(metaBucket, schemaVersionKey, []byte(strconv.Itoa(version)))
+}
+
+// isEmpty checks if a database has no data yet
+func isEmpty(store Store) (bool, error) {
+	for _, bucket := range buckets {
+		if bucket == metaBucket {
+			continue
+		}
+		count, err := store.Count(bucket)
+		if err != nil {
+			return false, errors.Wrapf(err, "Cannot count entries of %s bucket", bucket)
+		} else if count > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,280 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDbs returns a function creating an empty database per backend
+func testDbs() map[string]func(t *testing.T) *config.Db {
+	return map[string]func(t *testing.T) *config.Db{
+		"bolt": func(t *testing.T) *config.Db {
+			return &config.Db{Type: "bolt", Path: filepath.Join(t.TempDir(), "ftpgrab.db")}
+		},
+		"sqlite": func(t *testing.T) *config.Db {
+			return &config.Db{Type: "sqlite", Path: filepath.Join(t.TempDir(), "ftpgrab.sqlite")}
+		},
+		"redis": func(t *testing.T) *config.Db {
+			return &config.Db{Type: "redis", Redis: &config.DbRedis{
+				Addr:      miniredis.RunT(t).Addr(),
+				KeyPrefix: "ftpgrab",
+			}}
+		},
+	}
+}
+
+// backupDb returns the configuration of a backup taken from a database
+func backupDb(cfg *config.Db, backup string) *config.Db {
+	if cfg.Type == "redis" {
+		redis := *cfg.Redis
+		redis.KeyPrefix = backup
+		return &config.Db{Type: cfg.Type, Redis: &redis}
+	}
+	return &config.Db{Type: cfg.Type, Path: backup}
+}
+
+// backupStore records backups taken from a store
+type backupStore struct {
+	Store
+	mu      sync.Mutex
+	backups []string
+}
+
+func (s *backupStore) Backup(name string) (string, error) {
+	backup, err := s.Store.Backup(name)
+	s.mu.Lock()
+	s.backups = append(s.backups, backup)
+	s.mu.Unlock()
+	return backup, err
+}
+
+// addTestMigration adds a migration to the next schema version and returns
+// the number of times it ran
+func addTestMigration(t *testing.T) *int32 {
+	var runs int32
+	previous := migrations
+	migrations = append(migrations[:len(migrations):len(migrations)], migration{
+		version: schemaVersion() + 1,
+		desc:    "Test migration",
+		up: func(store Store) error {
+			atomic.AddInt32(&runs, 1)
+			// Give concurrent upgrades a chance to overlap
+			time.Sleep(50 * time.Millisecond)
+			return store.Put(hashBucket, "migrated", []byte("true"))
+		},
+	})
+	t.Cleanup(func() { migrations = previous })
+	return &runs
+}
+
+func TestUpgradeNew(t *testing.T) {
+	for name, newDb := range testDbs() {
+		newDb := newDb
+		t.Run(name, func(t *testing.T) {
+			store, err := openStore(newDb(t), false)
+			require.NoError(t, err)
+			defer store.Close()
+
+			bs := &backupStore{Store: store}
+			require.NoError(t, upgrade(bs))
+			assert.Empty(t, bs.backups)
+
+			version, err := getVersion(store)
+			require.NoError(t, err)
+			assert.Equal(t, schemaVersion(), version)
+		})
+	}
+}
+
+func TestUpgradeLegacy(t *testing.T) {
+	for name, newDb := range testDbs() {
+		newDb := newDb
+		t.Run(name, func(t *testing.T) {
+			runs := addTestMigration(t)
+			cfg := newDb(t)
+
+			// Database created before schemas were versioned
+			store, err := openStore(cfg, false)
+			require.NoError(t, err)
+			defer store.Close()
+			require.NoError(t, store.Put(hashBucket, "hash", []byte("entry")))
+
+			bs := &backupStore{Store: store}
+			require.NoError(t, upgrade(bs))
+			assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+
+			version, err := getVersion(store)
+			require.NoError(t, err)
+			assert.Equal(t, schemaVersion(), version)
+			migrated, err := store.Has(hashBucket, "migrated")
+			require.NoError(t, err)
+			assert.True(t, migrated)
+
+			// Backup holds data as it was before migrating
+			require.Len(t, bs.backups, 1)
+			assert.Contains(t, bs.backups[0], "backup-v0-")
+			backup, err := openStore(backupDb(cfg, bs.backups[0]), false)
+			require.NoError(t, err)
+			defer backup.Close()
+			value, err := backup.Get(hashBucket, "hash")
+			require.NoError(t, err)
+			assert.Equal(t, "entry", string(value))
+			migrated, err = backup.Has(hashBucket, "migrated")
+			require.NoError(t, err)
+			assert.False(t, migrated)
+			version, err = getVersion(backup)
+			require.NoError(t, err)
+			assert.Equal(t, 0, version)
+
+			// Up to date database is left as is
+			require.NoError(t, upgrade(bs))
+			assert.Len(t, bs.backups, 1)
+			assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+		})
+	}
+}
+
+func TestUpgradeLegacyNoop(t *testing.T) {
+	for name, newDb := range testDbs() {
+		newDb := newDb
+		t.Run(name, func(t *testing.T) {
+			// Database created before schemas were versioned
+			store, err := openStore(newDb(t), false)
+			require.NoError(t, err)
+			defer store.Close()
+			require.NoError(t, store.Put(hashBucket, "hash", []byte("entry")))
+
+			// Pending migrations only record the version
+			bs := &backupStore{Store: store}
+			require.NoError(t, upgrade(bs))
+			assert.Empty(t, bs.backups)
+
+			version, err := getVersion(store)
+			require.NoError(t, err)
+			assert.Equal(t, schemaVersion(), version)
+			value, err := store.Get(hashBucket, "hash")
+			require.NoError(t, err)
+			assert.Equal(t, "entry", string(value))
+		})
+	}
+}
+
+func TestUpgradeNewer(t *testing.T) {
+	for name, newDb := range testDbs() {
+		newDb := newDb
+		t.Run(name, func(t *testing.T) {
+			store, err := openStore(newDb(t), false)
+			require.NoError(t, err)
+			defer store.Close()
+			require.NoError(t, setVersion(store, schemaVersion()+1))
+
+			err = upgrade(store)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "newer than supported")
+		})
+	}
+}
+
+func TestUpgradeConcurrent(t *testing.T) {
+	for name, newDb := range testDbs() {
+		if name == "bolt" {
+			// bolt files cannot be opened by several instances
+			continue
+		}
+		newDb := newDb
+		t.Run(name, func(t *testing.T) {
+			runs := addTestMigration(t)
+			cfg := newDb(t)
+
+			init, err := openStore(cfg, false)
+			require.NoError(t, err)
+			require.NoError(t, init.Put(hashBucket, "hash", []byte("entry")))
+			require.NoError(t, init.Close())
+
+			var wg sync.WaitGroup
+			errs := make([]error, 4)
+			for i := range errs {
+				store, err := openStore(cfg, false)
+				require.NoError(t, err)
+				defer store.Close()
+				wg.Add(1)
+				go func(i int, store Store) {
+					defer wg.Done()
+					errs[i] = upgrade(store)
+				}(i, store)
+			}
+			wg.Wait()
+
+			for _, err := range errs {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+		})
+	}
+}
+
+func TestUpgradeLock(t *testing.T) {
+	wait := upgradeLockWait
+	upgradeLockWait = 200 * time.Millisecond
+	t.Cleanup(func() { upgradeLockWait = wait })
+
+	for name, newDb := range testDbs() {
+		newDb := newDb
+		t.Run(name, func(t *testing.T) {
+			store, err := openStore(newDb(t), false)
+			require.NoError(t, err)
+			defer store.Close()
+
+			// Lock held by another instance
+			unlock, err := lockUpgrade(store)
+			require.NoError(t, err)
+			err = upgrade(store)
+			require.Error(t, err)
+			assert.True(t, strings.Contains(err.Error(), "another instance"))
+
+			// Lock released
+			unlock()
+			require.NoError(t, upgrade(store))
+			locked, err := store.Has(metaBucket, upgradeLockKey)
+			require.NoError(t, err)
+			assert.False(t, locked)
+
+			// Lock left by an instance that stopped while upgrading
+			require.NoError(t, setVersion(store, 0))
+			require.NoError(t, store.Put(metaBucket, upgradeLockKey, []byte("1-1")))
+			require.NoError(t, upgrade(store))
+			version, err := getVersion(store)
+			require.NoError(t, err)
+			assert.Equal(t, schemaVersion(), version)
+		})
+	}
+}
+
+func TestUpgradeOnly(t *testing.T) {
+	addTestMigration(t)
+	cfg := &config.Db{Type: "bolt", Path: filepath.Join(t.TempDir(), "ftpgrab.db")}
+	store, err := openStore(cfg, false)
+	require.NoError(t, err)
+	require.NoError(t, store.Put(hashBucket, "hash", []byte("entry")))
+	require.NoError(t, store.Close())
+
+	version, err := Upgrade(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, schemaVersion(), version)
+
+	matches, err := filepath.Glob(cfg.Path + ".backup-v0-*")
+	require.NoError(t, err)
+	assert.Len(t, matches, 1)
+
+	_, err = Upgrade(&config.Db{Type: "bolt"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite" // sqlite driver
+)
+
+// sqliteStore stores buckets in a SQLite table. WAL journaling and a busy
+// timeout let several instances share the same file.
+type sqliteStore struct {
+	db   *sql.DB
+	path string
+}
+
+func newSQLite(path string, readOnly bool) (*sqliteStore, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)", url.PathEscape(path))
+	if readOnly {
+		dsn = fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout(10000)", url.PathEscape(path))
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if readOnly {
+		if err = db.Ping(); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		return &sqliteStore{db: db, path: path}, nil
+	}
+
+	if _, err = db.Exec(`CREATE TABLE IF NOT EXISTS entries (
+		bucket TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value BLOB NOT NULL,
+		PRIMARY KEY (bucket, key)
+	)`); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "Cannot create entries table")
+	}
+
+	return &sqliteStore{db: db, path: path}, nil
+}
+
+func (s *sqliteStore) Has(bucket string, key string) (bool, error) {
+	value, err := s.Get(bucket, key)
+	return value != nil, err
+}
+
+func (s *sqliteStore) Get(bucket string, key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRow(`SELECT value FROM entries WHERE bucket = ? AND key = ?`, bucket, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (s *sqliteStore) Put(bucket string, key string, value []byte) error {
+	_, err := s.db.Exec(`INSERT INTO entries (bucket, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value`, bucket, key, value)
+	return err
+}
+
+func (s *sqliteStore) Swap(bucket string, key string, old []byte, new []byte) (bool, error) {
+	var res sql.Result
+	var err error
+	switch {
+	case old == nil && new == nil:
+		has, err := s.Has(bucket, key)
+		return !has, err
+	case old == nil:
+		res, err = s.db.Exec(`INSERT INTO entries (bucket, key, value) VALUES (?, ?, ?)
+			ON CONFLICT (bucket, key) DO NOTHING`, bucket, key, new)
+	case new == nil:
+		res, err = s.db.Exec(`DELETE FROM entries WHERE bucket = ? AND key = ? AND value = ?`, bucket, key, old)
+	default:
+		res, err = s.db.Exec(`UPDATE entries SET value = ? WHERE bucket = ? AND key = ? AND value = ?`, new, bucket, key, old)
+	}
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count == 1, err
+}
+
+func (s *sqliteStore) Delete(bucket string, key string) error {
+	_, err := s.db.Exec(`DELETE FROM entries WHERE bucket = ? AND key = ?`, bucket, key)
+	return err
+}
+
+func (s *sqliteStore) List(bucket string) ([]string, error) {
+	var keys []string
+	err := s.Iterate(bucket, func(key string, _ []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
+
+func (s *sqliteStore) Iterate(bucket string, fn func(key string, value []byte) error) error {
+	rows, err := s.db.Query(`SELECT key, value FROM entries WHERE bucket = ? ORDER BY key`, bucket)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqliteStore) Count(bucket string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM entries WHERE bucket = ?`, bucket).Scan(&count)
+	return count, err
+}
+
+// Backup copies the database file next to it
+func (s *sqliteStore) Backup(name string) (string, error) {
+	dest := fmt.Sprintf("%s.%s", s.path, name)
+	_, err := s.db.Exec(`VACUUM INTO ?`, dest)
+	return dest, err
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
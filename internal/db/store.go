@@ -0,0 +1,52 @@
+package db
+
+import (
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	// hashBucket holds the hash of downloaded files
+	hashBucket = "ftpgrab"
+
+	// watermarkBucket holds the newest modification time seen per source
+	watermarkBucket = "watermark"
+
+	// metaBucket holds database metadata like the schema version
+	metaBucket = "meta"
+)
+
+// buckets lists the buckets used by ftpgrab
+var buckets = []string{metaBucket, hashBucket, watermarkBucket}
+
+// Store is a key/value storage backend organized in buckets. Values passed
+// to the Iterate callback are only valid until it returns. Swap atomically
+// replaces the value of a key if it is still old and reports whether it did.
+// A nil old value stands for a missing key and a nil new value deletes it.
+type Store interface {
+	Has(bucket string, key string) (bool, error)
+	Get(bucket string, key string) ([]byte, error)
+	Put(bucket string, key string, value []byte) error
+	Swap(bucket string, key string, old []byte, new []byte) (bool, error)
+	Delete(bucket string, key string) error
+	List(bucket string) ([]string, error)
+	Iterate(bucket string, fn func(key string, value []byte) error) error
+	Count(bucket string) (int, error)
+	Backup(name string) (string, error)
+	Close() error
+}
+
+// openStore opens the storage backend of a database configuration. A
+// read-only bolt or sqlite database must exist and is not modified.
+func openStore(cfg *config.Db, readOnly bool) (Store, error) {
+	switch cfg.Type {
+	case "sqlite":
+		return newSQLite(cfg.Path, readOnly)
+	case "redis":
+		return newRedis(cfg.Redis)
+	case "bolt", "":
+		return newBolt(cfg.Path, readOnly)
+	default:
+		return nil, errors.Errorf("Unknown database type %s", cfg.Type)
+	}
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	for name, newDb := range testDbs() {
+		newDb := newDb
+		t.Run(name, func(t *testing.T) {
+			store, err := openStore(newDb(t), false)
+			require.NoError(t, err)
+			defer store.Close()
+
+			value, err := store.Get(hashBucket, "missing")
+			require.NoError(t, err)
+			assert.Nil(t, value)
+			has, err := store.Has(hashBucket, "missing")
+			require.NoError(t, err)
+			assert.False(t, has)
+
+			require.NoError(t, store.Put(hashBucket, "b", []byte("2")))
+			require.NoError(t, store.Put(hashBucket, "a", []byte("1")))
+			require.NoError(t, store.Put(hashBucket, "a", []byte("one")))
+			require.NoError(t, store.Put(watermarkBucket, "/src", []byte("watermark")))
+
+			value, err = store.Get(hashBucket, "a")
+			require.NoError(t, err)
+			assert.Equal(t, "one", string(value))
+			has, err = store.Has(hashBucket, "b")
+			require.NoError(t, err)
+			assert.True(t, has)
+
+			count, err := store.Count(hashBucket)
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+			keys, err := store.List(hashBucket)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"a", "b"}, keys)
+
+			entries := map[string]string{}
+			require.NoError(t, store.Iterate(hashBucket, func(key string, value []byte) error {
+				entries[key] = string(value)
+				return nil
+			}))
+			assert.Equal(t, map[string]string{"a": "one", "b": "2"}, entries)
+
+			require.NoError(t, store.Delete(hashBucket, "b"))
+			count, err = store.Count(hashBucket)
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+			count, err = store.Count(watermarkBucket)
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+		})
+	}
+}
+
+func TestStoreSwap(t *testing.T) {
+	for name, newDb := range testDbs() {
+		newDb := newDb
+		t.Run(name, func(t *testing.T) {
+			store, err := openStore(newDb(t), false)
+			require.NoError(t, err)
+			defer store.Close()
+
+			swapped, err := store.Swap(metaBucket, "key", nil, []byte("1"))
+			require.NoError(t, err)
+			assert.True(t, swapped)
+			swapped, err = store.Swap(metaBucket, "key", nil, []byte("2"))
+			require.NoError(t, err)
+			assert.False(t, swapped)
+
+			swapped, err = store.Swap(metaBucket, "key", []byte("2"), []byte("3"))
+			require.NoError(t, err)
+			assert.False(t, swapped)
+			swapped, err = store.Swap(metaBucket, "key", []byte("1"), []byte("3"))
+			require.NoError(t, err)
+			assert.True(t, swapped)
+
+			value, err := store.Get(metaBucket, "key")
+			require.NoError(t, err)
+			assert.Equal(t, "3", string(value))
+
+			swapped, err = store.Swap(metaBucket, "key", []byte("1"), nil)
+			require.NoError(t, err)
+			assert.False(t, swapped)
+			swapped, err = store.Swap(metaBucket, "key", []byte("3"), nil)
+			require.NoError(t, err)
+			assert.True(t, swapped)
+
+			has, err := store.Has(metaBucket, "key")
+			require.NoError(t, err)
+			assert.False(t, has)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package grabber
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// retryDelay returns the delay before a retry, growing exponentially with
+// the number of failed attempts and randomized by the jitter factor
+func (c *Client) retryDelay(attempts int) time.Duration {
+	backoff := c.config.Backoff
+	delay := float64(*backoff.Initial) * math.Pow(backoff.Multiplier, float64(attempts-1))
+	if delay > float64(*backoff.Max) {
+		delay = float64(*backoff.Max)
+	}
+	if backoff.Jitter > 0 {
+		delay += delay * backoff.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// sleep waits for the given delay and returns false if ctx is done before
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,95 @@
+package grabber
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/pkg/errors"
+)
+
+// Conflict policies applied when the destination file already exists
+const (
+	ConflictOverwrite = "overwrite"
+	ConflictSkip      = "skip"
+	ConflictRename    = "rename"
+	ConflictBackup    = "backup"
+	ConflictNewer     = "newer"
+)
+
+// resolveConflict applies the conflict policy on an existing destination file.
+// It returns the path to download to, a description of the action taken and
+// whether the download should be skipped.
+func (c *Client) resolveConflict(file File, destpath string) (string, string, bool, error) {
+	switch c.config.OnConflict {
+	case ConflictSkip:
+		return destpath, "Destination file exists, skipped by conflict policy", true, nil
+	case ConflictNewer:
+		destinfo, err := os.Stat(destpath)
+		if err != nil {
+			return "", "", false, err
+		}
+		if !file.Info.ModTime().After(destinfo.ModTime()) {
+			return destpath, "Destination file is not older than remote file, skipped by conflict policy", true, nil
+		}
+		return destpath, "overwritten as remote file is newer", false, nil
+	case ConflictRename:
+		// The remote file would otherwise be saved again on each run
+		if saved := findConflictCopy(file, destpath); len(saved) > 0 {
+			return destpath, fmt.Sprintf("Remote file already saved as %s, skipped by conflict policy", saved), true, nil
+		}
+		newpath := c.conflictPath(destpath)
+		return newpath, fmt.Sprintf("saved as %s", path.Base(newpath)), false, nil
+	case ConflictBackup:
+		backup := c.conflictPath(destpath)
+		if err := moveFile(destpath, backup); err != nil {
+			return "", "", false, errors.Wrap(err, "Cannot backup destination file")
+		}
+		return destpath, fmt.Sprintf("previous file backed up as %s", path.Base(backup)), false, nil
+	default:
+		return destpath, "overwritten", false, nil
+	}
+}
+
+// conflictPath returns a free path derived from filename using the configured suffix
+func (c *Client) conflictPath(filename string) string {
+	if c.config.ConflictSuffix == "timestamp" {
+		filename = fmt.Sprintf("%s.%s", filename, time.Now().Format("20060102150405"))
+		if !utl.Exists(filename) {
+			return filename
+		}
+	}
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s.%d", filename, i)
+		if !utl.Exists(candidate) {
+			return candidate
+		}
+	}
+}
+
+// findConflictCopy returns the name of a renamed copy of the destination
+// file having the size and modification time of the remote file
+func findConflictCopy(file File, destpath string) string {
+	entries, err := os.ReadDir(filepath.Dir(destpath))
+	if err != nil {
+		return ""
+	}
+	prefix := filepath.Base(destpath) + "."
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.Size() == file.Info.Size() && info.ModTime().Truncate(time.Second).Equal(file.Info.ModTime().Truncate(time.Second)) {
+			return entry.Name()
+		}
+	}
+	return ""
+}
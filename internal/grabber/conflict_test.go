@@ -0,0 +1,53 @@
+package grabber
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConflictRename(t *testing.T) {
+	srv := newTestServer()
+	c := newTestClient(t, srv, func(dlConfig *config.Download) {
+		dlConfig.OnConflict = ConflictRename
+	})
+	now := time.Now().Truncate(time.Second)
+
+	download := func(content string, mtime time.Time) *journal.Entry {
+		file := listed(c, srv.put("/src/file.txt", []byte(content), mtime))
+		return c.download(context.Background(), file)
+	}
+	readFile := func(name string) string {
+		b, err := os.ReadFile(path.Join(c.config.Output, name))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	entry := download("first", now.Add(-3*time.Hour))
+	require.Equal(t, journal.EntryLevelSuccess, entry.Level, entry.Text)
+	assert.Equal(t, "first", readFile("file.txt"))
+
+	// Remote file changed and is already recorded in the database
+	entry = download("second version", now.Add(-2*time.Hour))
+	require.Equal(t, journal.EntryLevelSuccess, entry.Level, entry.Text)
+	assert.Equal(t, "first", readFile("file.txt"))
+	assert.Equal(t, "second version", readFile("file.txt.1"))
+
+	// Remote file unchanged since it was renamed
+	entry = download("second version", now.Add(-2*time.Hour))
+	assert.Equal(t, journal.EntryLevelSkip, entry.Level, entry.Text)
+	_, err := os.Stat(path.Join(c.config.Output, "file.txt.2"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Remote file changed again
+	entry = download("third version!", now.Add(-time.Hour))
+	require.Equal(t, journal.EntryLevelSuccess, entry.Level, entry.Text)
+	assert.Equal(t, "third version!", readFile("file.txt.2"))
+}
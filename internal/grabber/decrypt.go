@@ -0,0 +1,184 @@
+package grabber
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/pkg/errors"
+)
+
+// encryptedExts lists extensions stripped from decrypted files
+var encryptedExts = []string{".gpg", ".pgp", ".asc"}
+
+// decrypter holds OpenPGP keys used to decrypt downloaded files
+type decrypter struct {
+	include         []*regexp.Regexp
+	keys            openpgp.EntityList
+	keyring         openpgp.EntityList
+	deleteEncrypted bool
+}
+
+func newDecrypter(cfg *config.DownloadDecrypt) (*decrypter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	d := &decrypter{
+		deleteEncrypted: *cfg.DeleteEncrypted,
+	}
+	for _, include := range cfg.Include {
+		re, err := regexp.Compile(include)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Cannot compile decrypt include regex '%s'", include)
+		}
+		d.include = append(d.include, re)
+	}
+
+	var err error
+	if d.keys, err = readKeyRing(cfg.KeyFile); err != nil {
+		return nil, errors.Wrap(err, "Cannot read decryption key")
+	}
+	passphrase, err := utl.GetSecret(cfg.KeyPassphrase, cfg.KeyPassphraseFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot retrieve decryption key passphrase")
+	}
+	for _, entity := range d.keys {
+		if entity.PrivateKey == nil {
+			return nil, errors.Errorf("No private key found for %X", entity.PrimaryKey.Fingerprint)
+		}
+		if err = entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+			return nil, errors.Wrap(err, "Cannot unlock decryption key")
+		}
+	}
+
+	if len(cfg.KeyringFile) > 0 {
+		if d.keyring, err = readKeyRing(cfg.KeyringFile); err != nil {
+			return nil, errors.Wrap(err, "Cannot read signature keyring")
+		}
+	}
+
+	return d, nil
+}
+
+// readKeyRing reads armored or binary OpenPGP keys from a file
+func readKeyRing(filename string) (openpgp.EntityList, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN PGP")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(b))
+}
+
+// matchDecrypt checks if a file has to be decrypted
+func (c *Client) matchDecrypt(file File) bool {
+	if c.decrypter == nil {
+		return false
+	}
+	for _, include := range c.decrypter.include {
+		if include.MatchString(file.Info.Name()) {
+			return true
+		}
+	}
+	return false
+}
+
+// decrypt decrypts a downloaded file next to it and returns the path of
+// the decrypted file. If a keyring is configured, the message must be
+// signed by one of its keys.
+func (c *Client) decrypt(encrypted string, modtime time.Time) (string, error) {
+	target := decryptedPath(encrypted)
+
+	f, err := os.Open(encrypted)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if peek, _ := r.(*bufio.Reader).Peek(64); bytes.HasPrefix(bytes.TrimSpace(peek), []byte("-----BEGIN PGP")) {
+		block, err := armor.Decode(r)
+		if err != nil {
+			return "", errors.Wrap(err, "Cannot decode armored message")
+		}
+		r = block.Body
+	}
+
+	keyring := append(openpgp.EntityList{}, c.decrypter.keyring...)
+	keyring = append(keyring, c.decrypter.keys...)
+	md, err := openpgp.ReadMessage(r, keyring, nil, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "Cannot decrypt message")
+	}
+	if !md.IsEncrypted {
+		return "", errors.New("Message is not encrypted")
+	}
+
+	// The body is written to a temp file until its signature is verified so
+	// unverified content never lands in the output tree
+	tempfile := filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".decrypting")
+	if err = c.writeFile(tempfile, md.UnverifiedBody, modtime); err != nil {
+		_ = os.Remove(tempfile)
+		return "", errors.Wrap(err, "Cannot write decrypted file")
+	}
+
+	if len(c.decrypter.keyring) > 0 {
+		if err = verifySignature(md, c.decrypter.keyring); err != nil {
+			_ = os.Remove(tempfile)
+			return "", err
+		}
+	}
+
+	if err = os.Rename(tempfile, target); err != nil {
+		_ = os.Remove(tempfile)
+		return "", errors.Wrap(err, "Cannot move decrypted file")
+	}
+
+	if c.decrypter.deleteEncrypted {
+		if err = os.Remove(encrypted); err != nil {
+			return target, errors.Wrap(err, "Cannot delete encrypted file")
+		}
+	}
+
+	return target, nil
+}
+
+// verifySignature checks the signature of a message once its body has
+// been fully read
+func verifySignature(md *openpgp.MessageDetails, keyring openpgp.EntityList) error {
+	if !md.IsSigned {
+		return errors.New("Signature verification failed: message is not signed")
+	}
+	if len(keyring.KeysById(md.SignedByKeyId)) == 0 {
+		return errors.Errorf("Signature verification failed: unknown signer key %X", md.SignedByKeyId)
+	}
+	if md.SignatureError != nil {
+		return errors.Wrap(md.SignatureError, "Signature verification failed")
+	}
+	if md.Signature == nil {
+		return errors.New("Signature verification failed: no valid signature found")
+	}
+	return nil
+}
+
+func decryptedPath(encrypted string) string {
+	ext := filepath.Ext(encrypted)
+	for _, encExt := range encryptedExts {
+		if strings.EqualFold(ext, encExt) {
+			return strings.TrimSuffix(encrypted, ext)
+		}
+	}
+	return encrypted + ".decrypted"
+}
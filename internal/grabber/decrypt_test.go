@@ -0,0 +1,90 @@
+package grabber
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEntity(t *testing.T, name string) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@ftpgrab.test", &packet.Config{
+		Algorithm: packet.PubKeyAlgoEdDSA,
+	})
+	require.NoError(t, err)
+	return entity
+}
+
+func encryptMessage(t *testing.T, to *openpgp.Entity, signer *openpgp.Entity, plaintext string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := openpgp.Encrypt(&buf, openpgp.EntityList{to}, signer, nil, nil)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(plaintext))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDownloadDecrypt(t *testing.T) {
+	recipient := newTestEntity(t, "recipient")
+	trusted := newTestEntity(t, "trusted")
+	untrusted := newTestEntity(t, "untrusted")
+
+	cases := []struct {
+		name    string
+		signer  *openpgp.Entity
+		level   journal.EntryLevel
+		decrypt bool
+	}{
+		{"trusted signer", trusted, journal.EntryLevelSuccess, true},
+		{"untrusted signer", untrusted, journal.EntryLevelError, false},
+		{"not signed", nil, journal.EntryLevelError, false},
+	}
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer()
+			c := newTestClient(t, srv, func(dlConfig *config.Download) {
+				dlConfig.PostAction = "delete"
+			})
+			c.decrypter = &decrypter{
+				include: []*regexp.Regexp{regexp.MustCompile(`\.gpg$`)},
+				keys:    openpgp.EntityList{recipient},
+				keyring: openpgp.EntityList{trusted},
+			}
+
+			file := listed(c, srv.put("/src/file.txt.gpg", encryptMessage(t, recipient, tt.signer, "content"), time.Now().Add(-time.Hour)))
+			entry := c.download(context.Background(), file)
+			assert.Equal(t, tt.level, entry.Level, entry.Text)
+
+			decrypted := path.Join(file.DestDir, "file.txt")
+			if !tt.decrypt {
+				// Nothing is left in the output and the file is retried
+				entries, err := os.ReadDir(file.DestDir)
+				require.NoError(t, err)
+				assert.Empty(t, entries)
+				assert.False(t, c.db.HasHash(file.Base, file.SrcDir, file.Info))
+				assert.Empty(t, srv.removed)
+				return
+			}
+
+			content, err := os.ReadFile(decrypted)
+			require.NoError(t, err)
+			assert.Equal(t, "content", string(content))
+			assert.True(t, c.db.HasHash(file.Base, file.SrcDir, file.Info))
+			assert.Equal(t, []string{"/src/file.txt.gpg"}, srv.removed)
+		})
+	}
+}
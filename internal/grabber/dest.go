@@ -0,0 +1,75 @@
+package grabber
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// destData holds data available in the destination template
+type destData struct {
+	Source   string
+	Dir      string
+	Name     string
+	Basename string
+	Ext      string
+	Size     int64
+	ModTime  time.Time
+	Groups   []string
+	Named    map[string]string
+}
+
+// destPath returns the local path of a file, evaluating the destination
+// template or flattening the remote tree if configured
+func (c *Client) destPath(file File) (string, error) {
+	if c.config.DestTpl == nil {
+		if *c.config.Flatten {
+			return path.Join(c.baseDest(file.Base), file.Info.Name()), nil
+		}
+		return path.Join(file.DestDir, file.Info.Name()), nil
+	}
+
+	name := file.Info.Name()
+	ext := path.Ext(name)
+	data := destData{
+		Source:   strings.Trim(file.Base, "/"),
+		Dir:      strings.Trim(strings.TrimPrefix(file.SrcDir, file.Base), "/"),
+		Name:     name,
+		Basename: strings.TrimSuffix(name, ext),
+		Ext:      ext,
+		Size:     file.Info.Size(),
+		ModTime:  file.Info.ModTime(),
+		Named:    map[string]string{},
+	}
+	if c.config.DestRe != nil {
+		data.Groups = c.config.DestRe.FindStringSubmatch(name)
+		for i, group := range c.config.DestRe.SubexpNames() {
+			if i > 0 && len(group) > 0 && i < len(data.Groups) {
+				data.Named[group] = data.Groups[i]
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c.config.DestTpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrap(err, "Cannot evaluate destination template")
+	}
+
+	rel := path.Clean("/" + strings.TrimSpace(buf.String()))
+	if rel == "/" {
+		return "", errors.New("Destination template evaluates to an empty path")
+	}
+
+	return path.Join(c.config.Output, rel), nil
+}
+
+// baseDest returns the destination folder of a source
+func (c *Client) baseDest(src string) string {
+	if src != "/" && *c.config.CreateBaseDir {
+		return path.Join(c.config.Output, src)
+	}
+	return c.config.Output
+}
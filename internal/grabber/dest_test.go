@@ -0,0 +1,49 @@
+package grabber
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestTemplate(t *testing.T) {
+	srv := newTestServer()
+	c := newTestClient(t, srv, func(dlConfig *config.Download) {
+		dlConfig.DestTpl = template.Must(template.New("dest").Option("missingkey=zero").Parse(`{{ .Ext }}/{{ .Name }}`))
+	})
+
+	file := listed(c, srv.put("/src/dir/file.txt", []byte("content"), time.Now().Add(-time.Hour)))
+	entry := c.download(context.Background(), file)
+	require.Equal(t, journal.EntryLevelSuccess, entry.Level, entry.Text)
+	assert.Equal(t, journal.EntryStatusNeverDl, entry.Status)
+	assert.FileExists(t, filepath.Join(c.config.Output, ".txt", "file.txt"))
+}
+
+func TestDestTemplateError(t *testing.T) {
+	srv := newTestServer()
+	c := newTestClient(t, srv, func(dlConfig *config.Download) {
+		dlConfig.Exclude = []string{`\.log$`}
+		dlConfig.DestTpl = template.Must(template.New("dest").Option("missingkey=zero").Parse(`{{ if ne .Ext ".txt" }}{{ .Name }}{{ end }}`))
+	})
+
+	// Destination evaluates to an empty path
+	file := listed(c, srv.put("/src/file.txt", []byte("content"), time.Now().Add(-time.Hour)))
+	entry := c.download(context.Background(), file)
+	assert.Equal(t, journal.EntryLevelError, entry.Level)
+	assert.Equal(t, journal.EntryStatusNeverDl, entry.Status)
+	assert.Contains(t, entry.Text, "Cannot resolve destination")
+
+	// Excluded files are skipped before their destination matters
+	c.config.DestTpl = template.Must(template.New("dest").Parse(`{{ .Missing.Field }}`))
+	file = listed(c, srv.put("/src/file.log", []byte("content"), time.Now().Add(-time.Hour)))
+	entry = c.download(context.Background(), file)
+	assert.Equal(t, journal.EntryLevelSkip, entry.Level, entry.Text)
+	assert.Equal(t, journal.EntryStatusExcluded, entry.Status)
+}
@@ -0,0 +1,366 @@
+package grabber
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// archiveExts maps supported archive extensions, longest first
+var archiveExts = []string{".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar", ".zip", ".gz", ".bz2"}
+
+// extractRule is a compiled extraction configuration
+type extractRule struct {
+	include       *regexp.Regexp
+	dest          *template.Template
+	deleteArchive bool
+	maxSize       int64
+	maxRatio      int
+}
+
+// extractData holds data available in the extraction destination template
+type extractData struct {
+	Name     string
+	Basename string
+	Ext      string
+	ModTime  time.Time
+}
+
+func compileExtract(cfg []config.DownloadExtract) ([]extractRule, error) {
+	var rules []extractRule
+	for _, extract := range cfg {
+		include, err := regexp.Compile(extract.Include)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Cannot compile extract include regex '%s'", extract.Include)
+		}
+		dest, err := template.New("extract").Parse(extract.Dest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Cannot parse extract destination template '%s'", extract.Dest)
+		}
+		rules = append(rules, extractRule{
+			include:       include,
+			dest:          dest,
+			deleteArchive: *extract.DeleteArchive,
+			maxSize:       extract.MaxSizeBytes,
+			maxRatio:      extract.MaxRatio,
+		})
+	}
+	return rules, nil
+}
+
+// matchExtract returns the first extraction rule matching a filename
+func (c *Client) matchExtract(name string) *extractRule {
+	for i := range c.extracts {
+		if c.extracts[i].include.MatchString(name) {
+			return &c.extracts[i]
+		}
+	}
+	return nil
+}
+
+// extract unpacks an archive into the destination evaluated from the rule
+// and returns the destination folder
+func (c *Client) extract(rule *extractRule, archive string, modtime time.Time) (string, error) {
+	name := filepath.Base(archive)
+	ext := archiveExt(name)
+	if len(ext) == 0 {
+		return "", errors.Errorf("Unsupported archive format for %s", name)
+	}
+
+	var buf bytes.Buffer
+	if err := rule.dest.Execute(&buf, extractData{
+		Name:     name,
+		Basename: strings.TrimSuffix(name, ext),
+		Ext:      ext,
+		ModTime:  modtime,
+	}); err != nil {
+		return "", errors.Wrap(err, "Cannot evaluate extract destination template")
+	}
+	dest := filepath.Clean(strings.TrimSpace(buf.String()))
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(archive), dest)
+	}
+
+	limit, err := newExtractLimit(rule, archive)
+	if err != nil {
+		return "", err
+	}
+	if err := c.mkdirAll(dest); err != nil {
+		return "", err
+	}
+
+	switch strings.ToLower(ext) {
+	case ".zip":
+		err = c.extractZip(archive, dest, limit)
+	case ".tar":
+		err = c.extractTar(archive, dest, limit, nil)
+	case ".tar.gz", ".tgz":
+		err = c.extractTar(archive, dest, limit, func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		})
+	case ".tar.bz2", ".tbz2":
+		err = c.extractTar(archive, dest, limit, func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		})
+	case ".gz":
+		err = c.extractSingle(archive, filepath.Join(dest, strings.TrimSuffix(name, ext)), limit, func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		})
+	case ".bz2":
+		err = c.extractSingle(archive, filepath.Join(dest, strings.TrimSuffix(name, ext)), limit, func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if rule.deleteArchive {
+		if err = os.Remove(archive); err != nil {
+			return dest, errors.Wrap(err, "Cannot delete archive")
+		}
+	}
+
+	return dest, nil
+}
+
+func (c *Client) extractZip(archive string, dest string, limit *extractLimit) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		target, err := safeJoin(dest, zf.Name)
+		if err != nil {
+			return err
+		}
+		if zf.FileInfo().IsDir() {
+			if err = c.mkdirAll(target); err != nil {
+				return err
+			}
+			continue
+		}
+		if !zf.Mode().IsRegular() {
+			log.Debug().Str("archive", archive).Msgf("Skip non-regular file %s", zf.Name)
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = c.writeFile(target, limit.reader(rc), zf.Modified)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) extractTar(archive string, dest string, limit *extractLimit, decompress func(io.Reader) (io.Reader, error)) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if decompress != nil {
+		if r, err = decompress(f); err != nil {
+			return err
+		}
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		target, err := safeJoin(dest, hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = c.mkdirAll(target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err = c.writeFile(target, limit.reader(tr), hdr.ModTime); err != nil {
+				return err
+			}
+		default:
+			log.Debug().Str("archive", archive).Msgf("Skip non-regular file %s", hdr.Name)
+		}
+	}
+}
+
+func (c *Client) extractSingle(archive string, target string, limit *extractLimit, decompress func(io.Reader) (io.Reader, error)) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := decompress(f)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return c.writeFile(target, limit.reader(r), fi.ModTime())
+}
+
+func (c *Client) writeFile(target string, r io.Reader, modtime time.Time) error {
+	if err := c.mkdirAll(filepath.Dir(target)); err != nil {
+		return err
+	}
+	if err := removeLink(target); err != nil {
+		return err
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		_ = os.Remove(target)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = c.fixPerms(target); err != nil {
+		log.Warn().Err(err).Str("file", target).Msg("Cannot fix file permissions")
+	}
+	if !modtime.IsZero() {
+		if err = os.Chtimes(target, modtime, modtime); err != nil {
+			log.Warn().Err(err).Str("file", target).Msg("Cannot change modtime of extracted file")
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) mkdirAll(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := c.fixPerms(dir); err != nil {
+		log.Warn().Err(err).Str("dir", dir).Msg("Cannot fix folder permissions")
+	}
+	return nil
+}
+
+// safeJoin joins an archive entry name to the destination folder and
+// makes sure the result does not escape it (zip slip)
+func safeJoin(dest string, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", errors.Errorf("Illegal file path in archive: %s", name)
+	}
+	target := filepath.Join(dest, name)
+	if !isWithin(dest, target) {
+		return "", errors.Errorf("Illegal file path in archive: %s", name)
+	}
+	return target, nil
+}
+
+// isWithin checks if target is dir or one of its descendants
+func isWithin(dir string, target string) bool {
+	rel, err := filepath.Rel(dir, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// extractLimit caps the total size of files extracted from an archive to
+// protect the output folder from archive bombs
+type extractLimit struct {
+	remaining int64
+	max       int64
+}
+
+// newExtractLimit returns the limit of an archive from the max size and
+// max compression ratio of a rule, nil if there is none
+func newExtractLimit(rule *extractRule, archive string) (*extractLimit, error) {
+	max := rule.maxSize
+	if rule.maxRatio > 0 {
+		fi, err := os.Stat(archive)
+		if err != nil {
+			return nil, err
+		}
+		if byRatio := fi.Size() * int64(rule.maxRatio); max == 0 || byRatio < max {
+			max = byRatio
+		}
+	}
+	if max == 0 {
+		return nil, nil
+	}
+	return &extractLimit{remaining: max, max: max}, nil
+}
+
+// reader returns r failing once the limit is exceeded
+func (l *extractLimit) reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{r: r, limit: l}
+}
+
+type limitedReader struct {
+	r     io.Reader
+	limit *extractLimit
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.limit.remaining < 0 {
+		return 0, l.limit.exceeded()
+	} else if l.limit.remaining < int64(len(p)) {
+		// Read one more byte to detect files exceeding the limit
+		p = p[:l.limit.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.limit.remaining -= int64(n)
+	if l.limit.remaining < 0 {
+		return n, l.limit.exceeded()
+	}
+	return n, err
+}
+
+func (l *extractLimit) exceeded() error {
+	return errors.Errorf("Extracted files exceed the limit of %s", units.HumanSize(float64(l.max)))
+}
+
+func archiveExt(name string) string {
+	lname := strings.ToLower(name)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(lname, ext) {
+			return name[len(name)-len(ext):]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package grabber
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type archiveFile struct {
+	name    string
+	content []byte
+}
+
+func writeZip(t *testing.T, filename string, files []archiveFile) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate})
+		require.NoError(t, err)
+		_, err = w.Write(file.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(filename, buf.Bytes(), 0o644))
+}
+
+func writeTarGz(t *testing.T, filename string, files []archiveFile) {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, file := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     file.name,
+			Mode:     0o644,
+			Size:     int64(len(file.content)),
+			Typeflag: tar.TypeReg,
+			ModTime:  time.Now(),
+		}))
+		_, err := tw.Write(file.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	require.NoError(t, os.WriteFile(filename, buf.Bytes(), 0o644))
+}
+
+func newExtractClient(t *testing.T, extract config.DownloadExtract) *Client {
+	t.Helper()
+	extract.Include = `\.(zip|tar\.gz)$`
+	if len(extract.Dest) == 0 {
+		extract.Dest = "{{ .Basename }}"
+	}
+	if extract.DeleteArchive == nil {
+		extract.DeleteArchive = utl.NewFalse()
+	}
+	c := newTestClient(t, newTestServer(), nil)
+	rules, err := compileExtract([]config.DownloadExtract{extract})
+	require.NoError(t, err)
+	c.extracts = rules
+	return c
+}
+
+func TestExtract(t *testing.T) {
+	c := newExtractClient(t, *(&config.DownloadExtract{}).GetDefaults())
+	files := []archiveFile{
+		{name: "dir/file.txt", content: []byte("content")},
+		{name: "other.txt", content: []byte("other")},
+	}
+
+	for _, name := range []string{"archive.zip", "archive.tar.gz"} {
+		archive := filepath.Join(c.config.Output, name)
+		if filepath.Ext(name) == ".zip" {
+			writeZip(t, archive, files)
+		} else {
+			writeTarGz(t, archive, files)
+		}
+
+		dest, err := c.extract(c.matchExtract(name), archive, time.Now())
+		require.NoError(t, err, name)
+		assert.Equal(t, filepath.Join(c.config.Output, "archive"), dest)
+		for _, file := range files {
+			content, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(file.name)))
+			require.NoError(t, err)
+			assert.Equal(t, file.content, content)
+		}
+		require.NoError(t, os.RemoveAll(dest))
+	}
+}
+
+func TestExtractZipSlip(t *testing.T) {
+	for _, entry := range []string{"../evil.txt", "dir/../../evil.txt", "/evil.txt", "/tmp/evil.txt"} {
+		entry := entry
+		t.Run(entry, func(t *testing.T) {
+			c := newExtractClient(t, *(&config.DownloadExtract{}).GetDefaults())
+			files := []archiveFile{{name: entry, content: []byte("evil")}}
+
+			for _, name := range []string{"archive.zip", "archive.tar.gz"} {
+				archive := filepath.Join(c.config.Output, name)
+				if filepath.Ext(name) == ".zip" {
+					writeZip(t, archive, files)
+				} else {
+					writeTarGz(t, archive, files)
+				}
+
+				_, err := c.extract(c.matchExtract(name), archive, time.Now())
+				require.Error(t, err, name)
+				assert.Contains(t, err.Error(), "Illegal file path")
+				assert.NoFileExists(t, filepath.Join(c.config.Output, "evil.txt"))
+				assert.NoFileExists(t, filepath.Join(c.config.Output, "archive", "evil.txt"))
+			}
+		})
+	}
+}
+
+func TestExtractLimit(t *testing.T) {
+	// Highly compressible content like archive bombs
+	bomb := []archiveFile{{name: "bomb.txt", content: make([]byte, 10<<20)}}
+
+	cases := []struct {
+		name    string
+		extract config.DownloadExtract
+		files   []archiveFile
+		wantErr bool
+	}{
+		{
+			name:    "max ratio exceeded",
+			extract: config.DownloadExtract{MaxRatio: 100},
+			files:   bomb,
+			wantErr: true,
+		},
+		{
+			name:    "max size exceeded",
+			extract: config.DownloadExtract{MaxSizeBytes: 1024},
+			files: []archiveFile{
+				{name: "a.txt", content: bytes.Repeat([]byte("a"), 600)},
+				{name: "b.txt", content: bytes.Repeat([]byte("b"), 600)},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "max size reached",
+			extract: config.DownloadExtract{MaxSizeBytes: 1200},
+			files: []archiveFile{
+				{name: "a.txt", content: bytes.Repeat([]byte("a"), 600)},
+				{name: "b.txt", content: bytes.Repeat([]byte("b"), 600)},
+			},
+		},
+		{
+			name:    "no limit",
+			extract: config.DownloadExtract{},
+			files:   bomb,
+		},
+	}
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := newExtractClient(t, tt.extract)
+			for _, name := range []string{"archive.zip", "archive.tar.gz"} {
+				archive := filepath.Join(c.config.Output, name)
+				if filepath.Ext(name) == ".zip" {
+					writeZip(t, archive, tt.files)
+				} else {
+					writeTarGz(t, archive, tt.files)
+				}
+
+				dest, err := c.extract(c.matchExtract(name), archive, time.Now())
+				if !tt.wantErr {
+					require.NoError(t, err, name)
+					require.NoError(t, os.RemoveAll(dest))
+					continue
+				}
+				require.Error(t, err, name)
+				assert.Contains(t, err.Error(), "exceed the limit")
+
+				// Partially extracted files are removed
+				last := tt.files[len(tt.files)-1]
+				assert.NoFileExists(t, filepath.Join(c.config.Output, "archive", last.name))
+				require.NoError(t, os.RemoveAll(filepath.Join(c.config.Output, "archive")))
+			}
+		})
+	}
+}
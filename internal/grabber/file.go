@@ -1,12 +1,21 @@
 package grabber
 
 import (
+	"context"
 	"os"
 	"path"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
+// listBufferSize is the number of listed items waiting to be processed
+// before listing is paused
+const listBufferSize = 1000
+
 // File represents a file to grab
 type File struct {
 	Base    string
@@ -15,55 +24,222 @@ type File struct {
 	Info    os.FileInfo
 }
 
-func (c *Client) ListFiles() []File {
-	var files []File
+// modTimeInfo overrides the modification time of a file
+type modTimeInfo struct {
+	os.FileInfo
+	mtime time.Time
+}
 
-	// Iterate sources
-	for _, src := range c.server.Common().Sources {
-		log.Debug().Str("source", src).Msg("Listing files")
+func (m *modTimeInfo) ModTime() time.Time {
+	return m.mtime
+}
 
-		// Check basedir
-		dest := c.config.Output
-		if src != "/" && *c.config.CreateBaseDir {
-			dest = path.Join(dest, src)
-		}
+// Item is a file found while listing sources or an error that occurred
+// while reading the directory File.SrcDir
+type Item struct {
+	File File
+	Err  error
+}
 
-		files = append(files, c.readDir(src, src, dest)...)
-	}
+// dir is a directory waiting to be read while listing sources
+type dir struct {
+	sc      *Client
+	base    string
+	srcdir  string
+	destdir string
+	// parents holds the resolved paths of the directories walked to reach
+	// srcdir, the last one being srcdir itself, to detect symlink loops
+	parents []string
+}
 
-	return files
+// dirQueue holds directories waiting to be read. It is unbounded so workers
+// never wait for each other to queue subdirectories.
+type dirQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	dirs    []dir
+	pending int
+	stopped bool
 }
 
-func (c *Client) readDir(base string, srcdir string, destdir string) []File {
-	var files []File
+func newDirQueue() *dirQueue {
+	q := &dirQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues a directory to read
+func (q *dirQueue) push(d dir) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dirs = append(q.dirs, d)
+	q.pending++
+	q.cond.Signal()
+}
 
-	items, err := c.server.ReadDir(srcdir)
-	if err != nil {
-		log.Error().Err(err).Str("source", base).Msgf("Cannot read directory %s", srcdir)
-		return []File{}
+// pop returns the next directory to read. It waits while other directories
+// being read may queue subdirectories and returns false once all
+// directories have been read or the queue is stopped.
+func (q *dirQueue) pop() (dir, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.dirs) == 0 && q.pending > 0 && !q.stopped {
+		q.cond.Wait()
+	}
+	if q.stopped || len(q.dirs) == 0 {
+		return dir{}, false
 	}
+	d := q.dirs[0]
+	q.dirs[0] = dir{}
+	q.dirs = q.dirs[1:]
+	return d, true
+}
 
-	for _, item := range items {
-		files = append(files, c.readFile(base, srcdir, destdir, item)...)
+// done marks a directory returned by pop as read
+func (q *dirQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending--; q.pending == 0 {
+		q.cond.Broadcast()
 	}
+}
 
-	return files
+// stop releases workers waiting for a directory
+func (q *dirQueue) stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.stopped = true
+	q.cond.Broadcast()
 }
 
-func (c *Client) readFile(base string, srcdir string, destdir string, file os.FileInfo) []File {
-	srcfile := path.Join(srcdir, file.Name())
-	destfile := path.Join(destdir, file.Name())
+// ListFiles walks sources and streams files as directories are read.
+// Directories are read by a fixed number of workers and the returned
+// channel is closed once all sources have been walked or ctx is done.
+func (c *Client) ListFiles(ctx context.Context) <-chan Item {
+	items := make(chan Item, listBufferSize)
+	queue := newDirQueue()
 
-	if file.IsDir() {
-		return c.readDir(base, srcfile, destfile)
+	// send returns false once listing has to stop
+	send := func(item Item) bool {
+		select {
+		case items <- item:
+			return true
+		case <-ctx.Done():
+			return false
+		}
 	}
 
-	return []File{
-		{
-			Base:    base,
-			SrcDir:  srcdir,
-			DestDir: destdir,
-			Info:    file,
-		},
+	walk := func(d dir) {
+		sc, base, srcdir := d.sc, d.base, d.srcdir
+		entries, err := sc.server.ReadDir(ctx, srcdir)
+		if ctx.Err() != nil {
+			return
+		} else if err != nil {
+			log.Error().Err(err).Str("source", base).Msgf("Cannot read directory %s", srcdir)
+			send(Item{File: File{Base: base, SrcDir: srcdir}, Err: errors.Wrap(err, "Cannot read directory")})
+			return
+		}
+
+		realdir := d.parents[len(d.parents)-1]
+		for _, entry := range entries {
+			srcfile := path.Join(srcdir, entry.Name())
+			realpath := path.Join(realdir, entry.Name())
+
+			if entry.Mode()&os.ModeSymlink != 0 {
+				switch sc.config.Symlinks {
+				case "skip":
+					log.Debug().Str("source", base).Msgf("Skip symbolic link %s", srcfile)
+					continue
+				case "follow":
+					if entry, realpath, err = sc.followLink(ctx, srcfile, realdir); ctx.Err() != nil {
+						return
+					} else if err != nil {
+						log.Error().Err(err).Str("source", base).Msgf("Cannot follow symbolic link %s", srcfile)
+						if !send(Item{File: File{Base: base, SrcDir: srcfile}, Err: errors.Wrap(err, "Cannot follow symbolic link")}) {
+							return
+						}
+						continue
+					}
+					if entry.IsDir() && isLoop(realpath, d.parents) {
+						log.Warn().Str("source", base).Msgf("Skip symbolic link %s causing a loop", srcfile)
+						continue
+					}
+				}
+			}
+
+			if entry.IsDir() {
+				if sc.isDirExcluded(base, srcfile) {
+					log.Debug().Str("source", base).Msgf("Skip excluded directory %s", srcfile)
+					continue
+				}
+				queue.push(dir{
+					sc:      sc,
+					base:    base,
+					srcdir:  srcfile,
+					destdir: path.Join(d.destdir, entry.Name()),
+					parents: append(d.parents[:len(d.parents):len(d.parents)], realpath),
+				})
+				continue
+			}
+			if !send(Item{File: File{
+				Base:    base,
+				SrcDir:  srcdir,
+				DestDir: d.destdir,
+				Info:    entry,
+			}}) {
+				return
+			}
+		}
+	}
+
+	// Iterate sources
+	for _, src := range c.server.Common().Sources {
+		log.Debug().Str("source", src.Path).Msg("Listing files")
+
+		sc := c.forSource(src.Path)
+		queue.push(dir{
+			sc:      sc,
+			base:    src.Path,
+			srcdir:  src.Path,
+			destdir: sc.baseDest(src.Path),
+			parents: []string{path.Clean(src.Path)},
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.config.ListWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				d, ok := queue.pop()
+				if !ok {
+					return
+				}
+				walk(d)
+				queue.done()
+			}
+		}()
 	}
+
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			queue.stop()
+		case <-finished:
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(finished)
+		close(items)
+	}()
+
+	return items
+}
+
+// relPath returns the path of a remote file or folder relative to its source
+func relPath(base string, p string) string {
+	return strings.Trim(strings.TrimPrefix(p, base), "/")
 }
@@ -0,0 +1,96 @@
+package grabber
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listAll drains listed items and returns the paths of files found
+func listAll(t *testing.T, items <-chan Item) []string {
+	t.Helper()
+
+	var files []string
+	for item := range items {
+		require.NoError(t, item.Err)
+		files = append(files, path.Join(item.File.SrcDir, item.File.Info.Name()))
+	}
+	return files
+}
+
+func TestListFiles(t *testing.T) {
+	srv := newTestServer()
+	c := newTestClient(t, srv, func(dlConfig *config.Download) {
+		dlConfig.ExcludeDir = []string{`^skip$`}
+	})
+
+	mtime := time.Now().Add(-time.Hour)
+	srv.put("/src/a.txt", []byte("a"), mtime)
+	srv.put("/src/dir/b.txt", []byte("b"), mtime)
+	srv.put("/src/dir/sub/c.txt", []byte("c"), mtime)
+	srv.put("/src/skip/d.txt", []byte("d"), mtime)
+
+	assert.ElementsMatch(t, []string{
+		"/src/a.txt",
+		"/src/dir/b.txt",
+		"/src/dir/sub/c.txt",
+	}, listAll(t, c.ListFiles(context.Background())))
+}
+
+func TestListFilesWorkers(t *testing.T) {
+	srv := newTestServer()
+	srv.readDelay = 5 * time.Millisecond
+	c := newTestClient(t, srv, func(dlConfig *config.Download) {
+		dlConfig.ListWorkers = 3
+	})
+
+	// A wide and deep tree has more directories than workers at each level
+	var expected []string
+	mtime := time.Now().Add(-time.Hour)
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 5; j++ {
+			name := fmt.Sprintf("/src/dir%d/sub%d/file.txt", i, j)
+			srv.put(name, []byte("content"), mtime)
+			expected = append(expected, name)
+		}
+	}
+
+	assert.ElementsMatch(t, expected, listAll(t, c.ListFiles(context.Background())))
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Equal(t, 3, srv.maxReading)
+	assert.Zero(t, srv.reading)
+}
+
+func TestListFilesCancel(t *testing.T) {
+	srv := newTestServer()
+	srv.readDelay = 5 * time.Millisecond
+	c := newTestClient(t, srv, nil)
+
+	mtime := time.Now().Add(-time.Hour)
+	for i := 0; i < 20; i++ {
+		srv.put(fmt.Sprintf("/src/dir%d/sub/file.txt", i), []byte("content"), mtime)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	items := c.ListFiles(ctx)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for range items {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listing not stopped once context is done")
+	}
+}
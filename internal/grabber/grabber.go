@@ -1,6 +1,7 @@
 package grabber
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
@@ -20,10 +21,13 @@ import (
 
 // Client represents an active grabber object
 type Client struct {
-	config  *config.Download
-	db      *db.Client
-	server  *server.Client
-	tempdir string
+	config    *config.Download
+	db        *db.Client
+	server    *server.Client
+	tempdir   string
+	extracts  []extractRule
+	decrypter *decrypter
+	sources   map[string]*config.Download
 }
 
 // New creates new grabber instance
@@ -32,6 +36,26 @@ func New(dlConfig *config.Download, dbConfig *config.Db, serverConfig *config.Se
 	var serverCli *server.Client
 	var err error
 
+	// Archive extraction rules
+	extracts, err := compileExtract(dlConfig.Extract)
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenPGP decryption
+	decrypter, err := newDecrypter(dlConfig.Decrypt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download settings overridden per source
+	sources := make(map[string]*config.Download)
+	for _, src := range serverConfig.Sources() {
+		if sources[src.Path], err = src.Override(dlConfig); err != nil {
+			return nil, err
+		}
+	}
+
 	// DB client
 	if dbCli, err = db.New(dbConfig); err != nil {
 		return nil, errors.Wrap(err, "Cannot open database")
@@ -55,43 +79,122 @@ func New(dlConfig *config.Download, dbConfig *config.Db, serverConfig *config.Se
 		return nil, errors.Wrap(err, "Cannot create temp dir")
 	}
 
-	return &Client{
-		config:  dlConfig,
-		db:      dbCli,
-		server:  serverCli,
-		tempdir: tempdir,
-	}, nil
+	c := &Client{
+		config:    dlConfig,
+		db:        dbCli,
+		server:    serverCli,
+		tempdir:   tempdir,
+		extracts:  extracts,
+		decrypter: decrypter,
+		sources:   sources,
+	}
+
+	// Relative and incremental since
+	if err = c.resolveSince(time.Now()); err != nil {
+		c.Close()
+		return nil, errors.Wrap(err, "Cannot resolve since")
+	}
+
+	return c, nil
 }
 
-func (c *Client) Grab(files []File) journal.Journal {
+// Grab downloads files as they are listed until ctx is done
+func (c *Client) Grab(ctx context.Context, items <-chan Item) journal.Journal {
 	jnl := journal.New()
 	jnl.ServerHost = c.server.Common().Host
 
-	for _, file := range files {
-		if entry := c.download(file, 0); entry != nil {
+	var count int
+	wm := newWatermarks()
+	for item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		if item.Err != nil {
+			entry := &journal.Entry{
+				File:       item.File.SrcDir,
+				Level:      journal.EntryLevelError,
+				Text:       item.Err.Error(),
+				ErrorClass: string(c.server.Classify(item.Err)),
+			}
+			jnl.Add(*entry)
+			wm.add(item.File, entry)
+			continue
+		}
+		count++
+		sc := c.forSource(item.File.Base)
+		entry := sc.download(ctx, item.File)
+		if entry.Level != journal.EntryLevelSkip || !*sc.config.HideSkipped {
 			jnl.Add(*entry)
 		}
+		wm.add(item.File, entry)
+	}
+
+	if err := ctx.Err(); err != nil {
+		text := "Run cancelled, remaining files not processed"
+		if err == context.DeadlineExceeded {
+			text = fmt.Sprintf("Run exceeded max duration of %s, remaining files not processed", *c.config.MaxDuration)
+		}
+		log.Error().Msg(text)
+		jnl.Add(journal.Entry{
+			Level: journal.EntryLevelError,
+			Text:  text,
+		})
+		log.Warn().Msg("Run interrupted, watermarks not updated")
+	} else {
+		c.saveWatermarks(wm)
+	}
+
+	if jnl.Reconnects = c.server.Reconnects(); jnl.Reconnects > 0 {
+		log.Warn().Msgf("Reconnected %d time(s) to the server", jnl.Reconnects)
+	}
+
+	if count == 0 {
+		log.Warn().Msg("No file found from the provided sources")
+	} else {
+		log.Info().Msgf("%d file(s) found", count)
 	}
 
 	return jnl.Journal
 }
 
-func (c *Client) download(file File, retry int) *journal.Entry {
+// forSource returns a copy of the client using download settings of a source
+func (c *Client) forSource(src string) *Client {
+	dlConfig, ok := c.sources[src]
+	if !ok {
+		return c
+	}
+	sc := *c
+	sc.config = dlConfig
+	return &sc
+}
+
+func (c *Client) download(ctx context.Context, file File) *journal.Entry {
 	srcpath := path.Join(file.SrcDir, file.Info.Name())
-	destpath := path.Join(file.DestDir, file.Info.Name())
 
 	entry := &journal.Entry{
-		File:   srcpath,
-		Status: c.getStatus(file),
+		File: srcpath,
+	}
+
+	// Files skipped by filters are reported as such even if their
+	// destination cannot be resolved
+	destpath, err := c.destPath(file)
+	entry.Status = c.getStatus(file, destpath)
+	if err != nil && !entry.Status.IsSkipped() {
+		log.Error().Err(err).Str("src", entry.File).Str("status", string(entry.Status)).Msg("Cannot resolve destination")
+		entry.Level = journal.EntryLevelError
+		entry.Text = fmt.Sprintf("Cannot resolve destination: %v", err)
+		return entry
 	}
 
 	sublogger := log.With().
 		Str("src", entry.File).
-		Str("dest", file.DestDir).
+		Str("dest", path.Dir(destpath)).
 		Str("size", units.HumanSize(float64(file.Info.Size()))).
 		Logger()
 
-	if entry.Status == journal.EntryStatusAlreadyDl && !c.db.HasHash(file.Base, file.SrcDir, file.Info) {
+	// Hashes are not needed with incremental mode as the watermark already
+	// prevents downloading files again
+	if entry.Status == journal.EntryStatusAlreadyDl && !c.config.Incremental && !c.db.HasHash(file.Base, file.SrcDir, file.Info) {
 		if err := c.db.PutHash(file.Base, file.SrcDir, file.Info); err != nil {
 			sublogger.Error().Err(err).Msg("Cannot add hash into db")
 			entry.Level = journal.EntryLevelWarning
@@ -102,11 +205,39 @@ func (c *Client) download(file File, retry int) *journal.Entry {
 
 	if entry.Status.IsSkipped() {
 		if !*c.config.HideSkipped {
-			sublogger.Warn().Msgf("Skipped (%s)", entry.Status)
+			sublogger.Warn().Str("status", string(entry.Status)).Msgf("Skipped (%s)", entry.Status)
+		}
+		entry.Level = journal.EntryLevelSkip
+		return entry
+	}
+
+	if isSymlink(file) {
+		return c.copyLink(ctx, file, srcpath, destpath, entry, sublogger)
+	}
+
+	if mtime, err := c.server.ModTime(ctx, srcpath); err != nil {
+		sublogger.Debug().Err(err).Msg("Cannot retrieve precise modtime, using listing one")
+	} else if !mtime.IsZero() {
+		file.Info = &modTimeInfo{FileInfo: file.Info, mtime: mtime}
+	}
+
+	var conflictAction string
+	if entry.Status == journal.EntryStatusSizeDiff {
+		var skip bool
+		if destpath, conflictAction, skip, err = c.resolveConflict(file, destpath); err != nil {
+			sublogger.Error().Err(err).Msg("Cannot resolve conflict with destination file")
+			entry.Level = journal.EntryLevelError
+			entry.Text = fmt.Sprintf("Cannot resolve conflict with destination file: %v", err)
+			return entry
+		} else if skip {
+			if !*c.config.HideSkipped {
+				sublogger.Warn().Str("status", string(entry.Status)).Msg(conflictAction)
+			}
 			entry.Level = journal.EntryLevelSkip
+			entry.Text = conflictAction
 			return entry
 		}
-		return nil
+		sublogger.Debug().Msgf("Destination file exists, %s", conflictAction)
 	}
 
 	retrieveStart := time.Now()
@@ -121,74 +252,156 @@ func (c *Client) download(file File, retry int) *journal.Entry {
 	if err := c.fixPerms(destfolder); err != nil {
 		sublogger.Warn().Err(err).Msg("Cannot fix parent folder permissions")
 	}
-
-	destfile, err := c.createFile(destpath)
-	if err != nil {
-		sublogger.Error().Err(err).Msg("Cannot create destination file")
+	if err := removeLink(destpath); err != nil {
+		sublogger.Error().Err(err).Msg("Cannot remove symbolic link at destination")
 		entry.Level = journal.EntryLevelError
-		entry.Text = fmt.Sprintf("Cannot create destination file: %v", err)
+		entry.Text = fmt.Sprintf("Cannot remove symbolic link at destination: %v", err)
 		return entry
 	}
-	defer destfile.Close()
 
-	err = c.server.Retrieve(srcpath, destfile)
-	if err != nil {
-		retry++
-		sublogger.Error().Err(err).Msgf("Error downloading, retry %d/%d", retry, c.config.Retry)
-		if retry == c.config.Retry {
-			sublogger.Error().Err(err).Msg("Cannot download file")
-			entry.Level = journal.EntryLevelError
-			entry.Text = fmt.Sprintf("Cannot download file: %v", err)
-		} else {
-			return c.download(file, retry)
+	for entry.Attempts = 1; ; entry.Attempts++ {
+		var class server.ErrorClass
+		if class, err = c.retrieve(ctx, srcpath, destpath); err == nil {
+			break
 		}
-	} else {
-		if err = destfile.Close(); err != nil {
-			sublogger.Error().Err(err).Msg("Cannot close destination file")
-			entry.Level = journal.EntryLevelError
-			entry.Text = fmt.Sprintf("Cannot close destination file: %v", err)
-			return entry
+		if class == server.ErrorTransient && entry.Attempts < c.config.Retry {
+			delay := c.retryDelay(entry.Attempts)
+			sublogger.Error().Err(err).Msgf("Error downloading, retry %d/%d in %s", entry.Attempts, c.config.Retry, delay.Round(time.Millisecond))
+			if sleep(ctx, delay) {
+				continue
+			}
 		}
+		sublogger.Error().Err(err).
+			Str("status", string(entry.Status)).
+			Int("attempts", entry.Attempts).
+			Str("class", string(class)).
+			Msg("Cannot download file")
+		entry.Level = journal.EntryLevelError
+		entry.Text = fmt.Sprintf("Cannot download file: %v", err)
+		entry.ErrorClass = string(class)
+		return entry
+	}
+
+	sublogger.Info().
+		Str("status", string(entry.Status)).
+		Str("duration", time.Since(retrieveStart).Round(time.Millisecond).String()).
+		Msg("File successfully downloaded")
+
+	entry.Level = journal.EntryLevelSuccess
+	entry.Text = fmt.Sprintf("%s successfully downloaded in %s",
+		units.HumanSize(float64(file.Info.Size())),
+		time.Since(retrieveStart).Round(time.Millisecond).String(),
+	)
+	if len(conflictAction) > 0 {
+		entry.Text = fmt.Sprintf("%s (%s)", entry.Text, conflictAction)
+	}
+	if err := c.fixPerms(destpath); err != nil {
+		sublogger.Warn().Err(err).Msg("Cannot fix file permissions")
+	}
+	if err = os.Chtimes(destpath, file.Info.ModTime(), file.Info.ModTime()); err != nil {
+		sublogger.Warn().Err(err).Msg("Cannot change modtime of destination file")
+	}
 
-		if *c.config.TempFirst {
-			log.Debug().
-				Str("tempfile", destfile.Name()).
-				Str("destfile", destpath).
-				Msgf("Move temp file")
-			err := moveFile(destfile.Name(), destpath)
-			if err != nil {
-				sublogger.Error().Err(err).Msg("Cannot move file")
-				entry.Level = journal.EntryLevelError
-				entry.Text = fmt.Sprintf("Cannot move file: %v", err)
-				return entry
+	// Decrypt before recording the hash and deleting the remote file so a
+	// file failing decryption or signature verification is retried
+	if c.matchDecrypt(file) {
+		decrypted, err := c.decrypt(destpath, file.Info.ModTime())
+		if err != nil {
+			sublogger.Error().Err(err).Msg("Cannot decrypt file")
+			if err := os.Remove(destpath); err != nil {
+				sublogger.Warn().Err(err).Msg("Cannot remove encrypted file")
 			}
+			entry.Level = journal.EntryLevelError
+			entry.Text = fmt.Sprintf("Successfully downloaded but cannot decrypt file: %v", err)
+			return entry
 		}
+		sublogger.Info().Str("decrypted", decrypted).Msg("File successfully decrypted")
+		entry.Text = fmt.Sprintf("%s and decrypted", entry.Text)
+		destpath = decrypted
+	}
 
-		sublogger.Info().
-			Str("duration", time.Since(retrieveStart).Round(time.Millisecond).String()).
-			Msg("File successfully downloaded")
-
-		entry.Level = journal.EntryLevelSuccess
-		entry.Text = fmt.Sprintf("%s successfully downloaded in %s",
-			units.HumanSize(float64(file.Info.Size())),
-			time.Since(retrieveStart).Round(time.Millisecond).String(),
-		)
-		if err := c.fixPerms(destpath); err != nil {
-			sublogger.Warn().Err(err).Msg("Cannot fix file permissions")
-		}
+	if !c.config.Incremental {
 		if err := c.db.PutHash(file.Base, file.SrcDir, file.Info); err != nil {
 			sublogger.Error().Err(err).Msg("Cannot add hash into db")
 			entry.Level = journal.EntryLevelWarning
 			entry.Text = fmt.Sprintf("Successfully downloaded but cannot add hash into db: %v", err)
 		}
-		if err = os.Chtimes(destpath, file.Info.ModTime(), file.Info.ModTime()); err != nil {
-			sublogger.Warn().Err(err).Msg("Cannot change modtime of destination file")
+	}
+
+	if c.config.PostAction == "delete" {
+		if err := c.server.Remove(ctx, srcpath); err != nil {
+			sublogger.Warn().Err(err).Msg("Cannot delete remote file")
+			entry.Level = journal.EntryLevelWarning
+			entry.Text = fmt.Sprintf("Successfully downloaded but cannot delete remote file: %v", err)
+		} else {
+			sublogger.Debug().Msg("Remote file deleted")
+		}
+	}
+
+	if rule := c.matchExtract(path.Base(destpath)); rule != nil {
+		extractdir, err := c.extract(rule, destpath, file.Info.ModTime())
+		if err != nil {
+			sublogger.Warn().Err(err).Msg("Cannot extract archive")
+			entry.Level = journal.EntryLevelWarning
+			entry.Text = fmt.Sprintf("Successfully downloaded but cannot extract archive: %v", err)
+		} else {
+			sublogger.Info().Str("extractdir", extractdir).Msg("Archive successfully extracted")
+			if entry.Level == journal.EntryLevelSuccess {
+				entry.Text = fmt.Sprintf("%s and extracted to %s", entry.Text, extractdir)
+			}
 		}
 	}
 
 	return entry
 }
 
+// retrieve downloads a remote file to its destination and returns the
+// class of the error if it fails. Only transfer failures occurring before
+// the end of the run can be transient.
+func (c *Client) retrieve(ctx context.Context, srcpath string, destpath string) (server.ErrorClass, error) {
+	destfile, err := c.createFile(destpath)
+	if err != nil {
+		return server.ErrorPermanent, errors.Wrap(err, "Cannot create destination file")
+	}
+	defer destfile.Close()
+
+	tctx, cancel := c.transferContext(ctx)
+	defer cancel()
+
+	dest := newProgressWriter(destfile)
+	stopIdle := func() bool { return false }
+	if *c.config.IdleTimeout > 0 {
+		stopIdle = watchIdle(dest, *c.config.IdleTimeout, cancel)
+	}
+
+	err = c.server.Retrieve(tctx, srcpath, dest)
+	idled := stopIdle()
+	if err != nil {
+		if ctx.Err() != nil {
+			return server.ErrorPermanent, c.transferError(ctx, tctx, idled, err)
+		} else if tctx.Err() != nil {
+			return server.ErrorTransient, c.transferError(ctx, tctx, idled, err)
+		}
+		return c.server.Classify(err), err
+	}
+
+	if err = destfile.Close(); err != nil {
+		return server.ErrorPermanent, errors.Wrap(err, "Cannot close destination file")
+	}
+
+	if *c.config.TempFirst {
+		log.Debug().
+			Str("tempfile", destfile.Name()).
+			Str("destfile", destpath).
+			Msgf("Move temp file")
+		if err = moveFile(destfile.Name(), destpath); err != nil {
+			return server.ErrorPermanent, errors.Wrap(err, "Cannot move file")
+		}
+	}
+
+	return "", nil
+}
+
 func (c *Client) createFile(filename string) (*os.File, error) {
 	if *c.config.TempFirst {
 		tempfile, err := os.CreateTemp(c.tempdir, path.Base(filename))
@@ -205,14 +418,33 @@ func (c *Client) createFile(filename string) (*os.File, error) {
 	return destfile, nil
 }
 
-func (c *Client) getStatus(file File) journal.EntryStatus {
+func (c *Client) getStatus(file File, destpath string) journal.EntryStatus {
 	if !c.isIncluded(file) {
 		return journal.EntryStatusNotIncluded
 	} else if c.isExcluded(file) {
 		return journal.EntryStatusExcluded
-	} else if file.Info.ModTime().Before(c.config.SinceTime) {
+	} else if !c.isPathIncluded(file) {
+		return journal.EntryStatusPathNotIncluded
+	} else if c.isPathExcluded(file) {
+		return journal.EntryStatusPathExcluded
+	} else if c.config.MinSizeBytes > 0 && !isSymlink(file) && file.Info.Size() < c.config.MinSizeBytes {
+		return journal.EntryStatusTooSmall
+	} else if c.config.MaxSizeBytes > 0 && !isSymlink(file) && file.Info.Size() > c.config.MaxSizeBytes {
+		return journal.EntryStatusTooLarge
+	} else if c.config.MinAgeDuration > 0 && time.Since(file.Info.ModTime()) < c.config.MinAgeDuration {
+		return journal.EntryStatusTooRecent
+	} else if c.config.MaxAgeDuration > 0 && time.Since(file.Info.ModTime()) > c.config.MaxAgeDuration {
+		return journal.EntryStatusTooOld
+	} else if c.isOutdated(file) {
 		return journal.EntryStatusOutdated
-	} else if destfile, err := os.Stat(path.Join(file.DestDir, file.Info.Name())); err == nil {
+	} else if isSymlink(file) {
+		if _, err := os.Lstat(destpath); err == nil {
+			return journal.EntryStatusAlreadyDl
+		} else if c.db.HasHash(file.Base, file.SrcDir, file.Info) {
+			return journal.EntryStatusHashExists
+		}
+		return journal.EntryStatusNeverDl
+	} else if destfile, err := os.Lstat(destpath); err == nil && destfile.Mode()&os.ModeSymlink == 0 {
 		if destfile.Size() == file.Info.Size() {
 			return journal.EntryStatusAlreadyDl
 		}
@@ -247,6 +479,45 @@ func (c *Client) isExcluded(file File) bool {
 	return false
 }
 
+func (c *Client) isPathIncluded(file File) bool {
+	if len(c.config.IncludePath) == 0 {
+		return true
+	}
+	relpath := relPath(file.Base, path.Join(file.SrcDir, file.Info.Name()))
+	for _, include := range c.config.IncludePath {
+		if utl.MatchString(include, relpath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) isPathExcluded(file File) bool {
+	if len(c.config.ExcludePath) == 0 {
+		return false
+	}
+	relpath := relPath(file.Base, path.Join(file.SrcDir, file.Info.Name()))
+	for _, exclude := range c.config.ExcludePath {
+		if utl.MatchString(exclude, relpath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) isDirExcluded(base string, dir string) bool {
+	if len(c.config.ExcludeDir) == 0 {
+		return false
+	}
+	reldir := relPath(base, dir)
+	for _, exclude := range c.config.ExcludeDir {
+		if utl.MatchString(exclude, reldir) {
+			return true
+		}
+	}
+	return false
+}
+
 // Close closes grabber
 func (c *Client) Close() {
 	if err := c.db.Close(); err != nil {
@@ -0,0 +1,260 @@
+package grabber
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/db"
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/crazy-max/ftpgrab/v7/internal/server"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fileInfo is a remote file for tests
+type fileInfo struct {
+	name  string
+	size  int64
+	mode  os.FileMode
+	mtime time.Time
+}
+
+func (f *fileInfo) Name() string       { return f.name }
+func (f *fileInfo) Size() int64        { return f.size }
+func (f *fileInfo) Mode() os.FileMode  { return f.mode }
+func (f *fileInfo) ModTime() time.Time { return f.mtime }
+func (f *fileInfo) IsDir() bool        { return f.mode.IsDir() }
+func (f *fileInfo) Sys() interface{}   { return nil }
+
+// testServer is an in-memory server handler for tests
+type testServer struct {
+	mu      sync.Mutex
+	files   map[string][]byte
+	mtimes  map[string]time.Time
+	links   map[string]string
+	removed []string
+	// reading and maxReading count directories being read
+	reading    int
+	maxReading int
+	readDelay  time.Duration
+}
+
+func newTestServer() *testServer {
+	return &testServer{
+		files:  make(map[string][]byte),
+		mtimes: make(map[string]time.Time),
+		links:  make(map[string]string),
+	}
+}
+
+// put adds a remote file and returns it as listed
+func (s *testServer) put(name string, data []byte, mtime time.Time) File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = data
+	s.mtimes[name] = mtime
+	return File{
+		Base:   "/src",
+		SrcDir: path.Dir(name),
+		Info:   &fileInfo{name: path.Base(name), size: int64(len(data)), mtime: mtime},
+	}
+}
+
+// link adds a remote symbolic link and returns it as listed
+func (s *testServer) link(name string, target string) File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.links[name] = target
+	return File{
+		Base:   "/src",
+		SrcDir: path.Dir(name),
+		Info:   &fileInfo{name: path.Base(name), mode: os.ModeSymlink, mtime: time.Now().Add(-time.Hour)},
+	}
+}
+
+func (s *testServer) Common() config.ServerCommon {
+	return config.ServerCommon{Host: "test", Sources: []config.Source{{Path: "/src"}}}
+}
+
+// ReadDir lists the files and folders implied by the paths of files added
+func (s *testServer) ReadDir(ctx context.Context, source string) ([]os.FileInfo, error) {
+	s.mu.Lock()
+	if s.reading++; s.reading > s.maxReading {
+		s.maxReading = s.reading
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.reading--
+		s.mu.Unlock()
+	}()
+	time.Sleep(s.readDelay)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []os.FileInfo
+	dirs := make(map[string]bool)
+	for name, data := range s.files {
+		if !strings.HasPrefix(name, source+"/") {
+			continue
+		}
+		rel := strings.TrimPrefix(name, source+"/")
+		if i := strings.Index(rel, "/"); i >= 0 {
+			if !dirs[rel[:i]] {
+				dirs[rel[:i]] = true
+				entries = append(entries, &fileInfo{name: rel[:i], mode: os.ModeDir | 0755})
+			}
+			continue
+		}
+		entries = append(entries, &fileInfo{name: rel, size: int64(len(data)), mtime: s.mtimes[name]})
+	}
+	return entries, nil
+}
+
+func (s *testServer) Stat(ctx context.Context, path string) (os.FileInfo, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *testServer) ReadLink(ctx context.Context, path string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	target, ok := s.links[path]
+	if !ok {
+		return "", os.ErrNotExist
+	}
+	return target, nil
+}
+
+func (s *testServer) ModTime(ctx context.Context, path string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mtimes[path], nil
+}
+
+func (s *testServer) Retrieve(ctx context.Context, path string, dest io.Writer) error {
+	s.mu.Lock()
+	data, ok := s.files[path]
+	s.mu.Unlock()
+	if !ok {
+		return os.ErrNotExist
+	}
+	_, err := io.Copy(dest, bytes.NewReader(data))
+	return err
+}
+
+func (s *testServer) Remove(ctx context.Context, path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, path)
+	s.removed = append(s.removed, path)
+	return nil
+}
+
+func (s *testServer) ConnLost(err error) bool {
+	return false
+}
+
+func (s *testServer) Classify(err error) server.ErrorClass {
+	return server.ErrorPermanent
+}
+
+func (s *testServer) Close() error {
+	return nil
+}
+
+// newTestClient returns a grabber downloading from srv with default
+// download settings altered by setup
+func newTestClient(t *testing.T, srv *testServer, setup func(dlConfig *config.Download)) *Client {
+	t.Helper()
+
+	dlConfig := (&config.Download{}).GetDefaults()
+	dlConfig.Output = t.TempDir()
+	if setup != nil {
+		setup(dlConfig)
+	}
+
+	dbcli, err := db.New(&config.Db{
+		Type: "bolt",
+		Path: filepath.Join(t.TempDir(), "ftpgrab.db"),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = dbcli.Close() })
+
+	servercli, err := server.New((&config.ServerReconnect{}).GetDefaults(), func() (server.Handler, error) {
+		return srv, nil
+	})
+	require.NoError(t, err)
+
+	return &Client{
+		config:  dlConfig,
+		db:      dbcli,
+		server:  servercli,
+		tempdir: t.TempDir(),
+		sources: map[string]*config.Download{"/src": dlConfig},
+	}
+}
+
+// listed sets the local folder of a remote file as listing does
+func listed(c *Client, file File) File {
+	file.DestDir = path.Join(c.config.Output, strings.TrimPrefix(file.SrcDir, file.Base))
+	return file
+}
+
+// captureLogs returns the events logged during a test by message
+func captureLogs(t *testing.T) func() map[string]map[string]interface{} {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = logger })
+
+	return func() map[string]map[string]interface{} {
+		events := make(map[string]map[string]interface{})
+		scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+		for scanner.Scan() {
+			var event map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+			events[event["message"].(string)] = event
+		}
+		return events
+	}
+}
+
+func TestDownloadLogStatus(t *testing.T) {
+	srv := newTestServer()
+	c := newTestClient(t, srv, func(dlConfig *config.Download) {
+		dlConfig.Exclude = []string{`\.log$`}
+	})
+	logs := captureLogs(t)
+
+	entry := c.download(context.Background(), listed(c, srv.put("/src/file.txt", []byte("content"), time.Now().Add(-time.Hour))))
+	require.Equal(t, journal.EntryLevelSuccess, entry.Level, entry.Text)
+	entry = c.download(context.Background(), listed(c, srv.put("/src/file.log", []byte("content"), time.Now().Add(-time.Hour))))
+	require.Equal(t, journal.EntryLevelSkip, entry.Level, entry.Text)
+
+	events := logs()
+	downloaded := events["File successfully downloaded"]
+	require.NotNil(t, downloaded)
+	assert.Equal(t, "/src/file.txt", downloaded["src"])
+	assert.Equal(t, string(journal.EntryStatusNeverDl), downloaded["status"])
+	skipped := events[fmt.Sprintf("Skipped (%s)", journal.EntryStatusExcluded)]
+	require.NotNil(t, skipped)
+	assert.Equal(t, "/src/file.log", skipped["src"])
+	assert.Equal(t, string(journal.EntryStatusExcluded), skipped["status"])
+}
@@ -0,0 +1,93 @@
+package grabber
+
+import (
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/rs/zerolog/log"
+)
+
+// resolveSince evaluates relative and incremental since settings of each
+// source for the current run
+func (c *Client) resolveSince(now time.Time) error {
+	for base, dlConfig := range c.sources {
+		if dlConfig.SinceDuration > 0 {
+			dlConfig.SinceTime = now.Add(-dlConfig.SinceDuration)
+		} else if dlConfig.Incremental {
+			watermark, err := c.db.GetWatermark(base)
+			if err != nil {
+				return err
+			}
+			dlConfig.SinceTime = watermark
+		} else {
+			continue
+		}
+		log.Debug().Str("source", base).Msgf("Only considering files modified since %s", dlConfig.SinceTime)
+	}
+	return nil
+}
+
+// isOutdated checks if a file is older than the since setting. With
+// incremental mode, files as old as the watermark are outdated as well.
+func (c *Client) isOutdated(file File) bool {
+	if c.config.Incremental && !c.config.SinceTime.IsZero() {
+		return !file.Info.ModTime().After(c.config.SinceTime)
+	}
+	return file.Info.ModTime().Before(c.config.SinceTime)
+}
+
+// watermarks tracks the newest modification time seen per source during a
+// run with incremental mode
+type watermarks struct {
+	newest map[string]time.Time
+	failed map[string]bool
+}
+
+func newWatermarks() *watermarks {
+	return &watermarks{
+		newest: make(map[string]time.Time),
+		failed: make(map[string]bool),
+	}
+}
+
+// add records the result of a file. Only files downloaded or already
+// present move the watermark, so files skipped by filters like min age are
+// considered again on next runs.
+func (w *watermarks) add(file File, entry *journal.Entry) {
+	if entry.Level == journal.EntryLevelError {
+		w.failed[file.Base] = true
+		return
+	}
+	if file.Info == nil || !file.Info.ModTime().After(w.newest[file.Base]) {
+		return
+	}
+	switch {
+	case entry.Level == journal.EntryLevelSuccess,
+		entry.Level == journal.EntryLevelWarning,
+		entry.Status == journal.EntryStatusAlreadyDl,
+		entry.Status == journal.EntryStatusHashExists,
+		entry.Status == journal.EntryStatusSizeDiff:
+		w.newest[file.Base] = file.Info.ModTime()
+	}
+}
+
+// saveWatermarks stores the newest modification time seen for each source
+// with incremental mode, unless a download failed for it
+func (c *Client) saveWatermarks(w *watermarks) {
+	for base, newest := range w.newest {
+		dlConfig, ok := c.sources[base]
+		if !ok || !dlConfig.Incremental {
+			continue
+		} else if w.failed[base] {
+			log.Warn().Str("source", base).Msg("Errors occurred, watermark not updated")
+			continue
+		} else if !newest.After(dlConfig.SinceTime) {
+			continue
+		}
+		if err := c.db.PutWatermark(base, newest); err != nil {
+			log.Error().Err(err).Str("source", base).Msg("Cannot store watermark")
+			continue
+		}
+		log.Debug().Str("source", base).Msgf("Watermark updated to %s", newest)
+	}
+}
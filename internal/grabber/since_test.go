@@ -0,0 +1,85 @@
+package grabber
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/db"
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrementalMinAge(t *testing.T) {
+	dbcli, err := db.New(&config.Db{
+		Type: "bolt",
+		Path: filepath.Join(t.TempDir(), "ftpgrab.db"),
+	})
+	require.NoError(t, err)
+	defer dbcli.Close()
+
+	dlConfig := &config.Download{
+		Incremental:    true,
+		MinAgeDuration: time.Hour,
+	}
+	c := &Client{
+		config:  dlConfig,
+		db:      dbcli,
+		sources: map[string]*config.Download{"/src": dlConfig},
+	}
+	output := t.TempDir()
+
+	now := time.Now()
+	older := File{Base: "/src", SrcDir: "/src", Info: &fileInfo{name: "older.txt", size: 1, mtime: now.Add(-2 * time.Hour)}}
+	young := File{Base: "/src", SrcDir: "/src", Info: &fileInfo{name: "young.txt", size: 1, mtime: now.Add(-10 * time.Minute)}}
+
+	// First run: the young file is too recent and must not move the watermark
+	require.NoError(t, c.resolveSince(now))
+	wm := newWatermarks()
+	assert.Equal(t, journal.EntryStatusNeverDl, c.getStatus(older, filepath.Join(output, "older.txt")))
+	wm.add(older, &journal.Entry{Status: journal.EntryStatusNeverDl, Level: journal.EntryLevelSuccess})
+	assert.Equal(t, journal.EntryStatusTooRecent, c.getStatus(young, filepath.Join(output, "young.txt")))
+	wm.add(young, &journal.Entry{Status: journal.EntryStatusTooRecent, Level: journal.EntryLevelSkip})
+	c.saveWatermarks(wm)
+
+	watermark, err := dbcli.GetWatermark("/src")
+	require.NoError(t, err)
+	assert.True(t, watermark.Equal(older.Info.ModTime()))
+
+	// Next run: the young file is old enough and must be downloaded
+	dlConfig.MinAgeDuration = 5 * time.Minute
+	require.NoError(t, c.resolveSince(now))
+	assert.Equal(t, journal.EntryStatusOutdated, c.getStatus(older, filepath.Join(output, "older.txt")))
+	assert.Equal(t, journal.EntryStatusNeverDl, c.getStatus(young, filepath.Join(output, "young.txt")))
+}
+
+func TestWatermarksAdd(t *testing.T) {
+	now := time.Now()
+	file := File{Base: "/src", SrcDir: "/src", Info: &fileInfo{name: "file.txt", mtime: now}}
+
+	cases := []struct {
+		name  string
+		entry journal.Entry
+		moved bool
+	}{
+		{"success", journal.Entry{Status: journal.EntryStatusNeverDl, Level: journal.EntryLevelSuccess}, true},
+		{"already downloaded", journal.Entry{Status: journal.EntryStatusAlreadyDl, Level: journal.EntryLevelSkip}, true},
+		{"hash exists", journal.Entry{Status: journal.EntryStatusHashExists, Level: journal.EntryLevelSkip}, true},
+		{"too recent", journal.Entry{Status: journal.EntryStatusTooRecent, Level: journal.EntryLevelSkip}, false},
+		{"not included", journal.Entry{Status: journal.EntryStatusNotIncluded, Level: journal.EntryLevelSkip}, false},
+		{"excluded", journal.Entry{Status: journal.EntryStatusExcluded, Level: journal.EntryLevelSkip}, false},
+		{"error", journal.Entry{Status: journal.EntryStatusNeverDl, Level: journal.EntryLevelError}, false},
+	}
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			wm := newWatermarks()
+			wm.add(file, &tt.entry)
+			_, ok := wm.newest["/src"]
+			assert.Equal(t, tt.moved, ok)
+			assert.Equal(t, tt.entry.Level == journal.EntryLevelError, wm.failed["/src"])
+		})
+	}
+}
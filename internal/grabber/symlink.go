@@ -0,0 +1,160 @@
+package grabber
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/crazy-max/ftpgrab/v7/internal/server"
+	"github.com/rs/zerolog"
+)
+
+// linkInfo is the os.FileInfo of a symbolic link target named after the link
+type linkInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (l *linkInfo) Name() string {
+	return l.name
+}
+
+// followLink returns the os.FileInfo of the target of a symbolic link
+// located in realdir and the resolved path of this target
+func (c *Client) followLink(ctx context.Context, srcfile string, realdir string) (os.FileInfo, string, error) {
+	target, err := c.server.ReadLink(ctx, srcfile)
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := c.server.Stat(ctx, srcfile)
+	if err != nil {
+		return nil, "", err
+	}
+	return &linkInfo{FileInfo: info, name: path.Base(srcfile)}, server.ResolveLink(realdir, target), nil
+}
+
+// isLoop checks if a directory is one of the walked directories or one of
+// their parents
+func isLoop(dir string, parents []string) bool {
+	if dir == "/" {
+		return true
+	}
+	for _, parent := range parents {
+		if parent == dir || strings.HasPrefix(parent, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isSymlink(file File) bool {
+	return file.Info.Mode()&os.ModeSymlink != 0
+}
+
+// copyLink recreates a remote symbolic link at destination. Absolute
+// targets within the source are made relative so the link remains valid
+// in the destination tree. Links to targets outside the output folder are
+// refused as files could otherwise be written through them.
+func (c *Client) copyLink(ctx context.Context, file File, srcpath string, destpath string, entry *journal.Entry, sublogger zerolog.Logger) *journal.Entry {
+	target, err := c.server.ReadLink(ctx, srcpath)
+	if err != nil {
+		sublogger.Error().Err(err).Msg("Cannot read symbolic link")
+		entry.Level = journal.EntryLevelError
+		entry.Text = fmt.Sprintf("Cannot read symbolic link: %v", err)
+		return entry
+	}
+
+	if path.IsAbs(target) && (path.Clean(target) == path.Clean(file.Base) || strings.HasPrefix(path.Clean(target), strings.TrimSuffix(file.Base, "/")+"/")) {
+		if rel, err := filepath.Rel(file.SrcDir, path.Clean(target)); err == nil {
+			target = filepath.ToSlash(rel)
+		}
+	}
+	if !path.IsAbs(target) {
+		target = path.Clean(target)
+	}
+	if !c.isLocalLink(destpath, target) {
+		sublogger.Error().Str("target", target).Msg("Symbolic link target is outside the output folder")
+		entry.Level = journal.EntryLevelError
+		entry.Text = fmt.Sprintf("Symbolic link target %s is outside the output folder", target)
+		return entry
+	}
+
+	if err := c.mkdirAll(path.Dir(destpath)); err != nil {
+		sublogger.Error().Err(err).Msg("Cannot create destination dir")
+		entry.Level = journal.EntryLevelError
+		entry.Text = fmt.Sprintf("Cannot create destination dir: %v", err)
+		return entry
+	}
+	if _, err := os.Lstat(destpath); err == nil {
+		if err := os.Remove(destpath); err != nil {
+			sublogger.Error().Err(err).Msg("Cannot remove destination file")
+			entry.Level = journal.EntryLevelError
+			entry.Text = fmt.Sprintf("Cannot remove destination file: %v", err)
+			return entry
+		}
+	}
+	if err := os.Symlink(target, destpath); err != nil {
+		sublogger.Error().Err(err).Msg("Cannot create symbolic link")
+		entry.Level = journal.EntryLevelError
+		entry.Text = fmt.Sprintf("Cannot create symbolic link: %v", err)
+		return entry
+	}
+
+	sublogger.Info().Str("status", string(entry.Status)).Str("target", target).Msg("Symbolic link successfully created")
+	entry.Level = journal.EntryLevelSuccess
+	entry.Text = fmt.Sprintf("Symbolic link created to %s", target)
+	if !c.config.Incremental {
+		if err := c.db.PutHash(file.Base, file.SrcDir, file.Info); err != nil {
+			sublogger.Error().Err(err).Msg("Cannot add hash into db")
+			entry.Level = journal.EntryLevelWarning
+			entry.Text = fmt.Sprintf("Symbolic link created but cannot add hash into db: %v", err)
+		}
+	}
+
+	return entry
+}
+
+// isLocalLink checks if a link to a clean target created at destpath
+// resolves within the output folder, following links already present on
+// the way
+func (c *Client) isLocalLink(destpath string, target string) bool {
+	if path.IsAbs(target) || filepath.IsAbs(target) {
+		return false
+	}
+	output := filepath.Clean(c.config.Output)
+	destdir := filepath.Dir(destpath)
+	if !isWithin(output, filepath.Join(destdir, filepath.FromSlash(target))) {
+		return false
+	}
+	realOutput, err := filepath.EvalSymlinks(output)
+	if err != nil {
+		return false
+	}
+	return isWithin(realOutput, evalExisting(filepath.Join(evalExisting(destdir), filepath.FromSlash(target))))
+}
+
+// evalExisting evaluates symbolic links in the longest existing part of a
+// path and appends the remaining part
+func evalExisting(name string) string {
+	for dir := name; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			rel, _ := filepath.Rel(dir, name)
+			return filepath.Join(real, rel)
+		} else if filepath.Dir(dir) == dir {
+			return name
+		}
+	}
+}
+
+// removeLink removes a symbolic link found at a local path so it is not
+// followed when writing a file there
+func removeLink(name string) error {
+	if fi, err := os.Lstat(name); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,94 @@
+package grabber
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyLink(t *testing.T) {
+	cases := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"relative", "file.txt", "file.txt"},
+		{"relative parent", "../other/file.txt", "../other/file.txt"},
+		{"not clean", "./dir/../file.txt", "file.txt"},
+		{"absolute within source", "/src/other/file.txt", "../other/file.txt"},
+		{"absolute outside source", "/root/.ssh/authorized_keys", ""},
+		{"relative outside output", "../../../etc/passwd", ""},
+		{"source root", "/src", ".."},
+	}
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer()
+			c := newTestClient(t, srv, func(dlConfig *config.Download) {
+				dlConfig.Symlinks = "copy-as-link"
+			})
+
+			file := listed(c, srv.link("/src/dir/link", tt.target))
+			entry := c.download(context.Background(), file)
+
+			destpath := path.Join(file.DestDir, "link")
+			if len(tt.want) == 0 {
+				assert.Equal(t, journal.EntryLevelError, entry.Level)
+				_, err := os.Lstat(destpath)
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			require.Equal(t, journal.EntryLevelSuccess, entry.Level, entry.Text)
+			target, err := os.Readlink(destpath)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, filepath.ToSlash(target))
+		})
+	}
+}
+
+func TestCopyLinkThroughLocalLink(t *testing.T) {
+	srv := newTestServer()
+	c := newTestClient(t, srv, func(dlConfig *config.Download) {
+		dlConfig.Symlinks = "copy-as-link"
+	})
+
+	// A link leaving the output folder already exists locally
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(c.config.Output, "escape")))
+
+	file := listed(c, srv.link("/src/link", "escape/authorized_keys"))
+	entry := c.download(context.Background(), file)
+	assert.Equal(t, journal.EntryLevelError, entry.Level)
+	_, err := os.Lstat(filepath.Join(c.config.Output, "link"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadOverLink(t *testing.T) {
+	srv := newTestServer()
+	c := newTestClient(t, srv, nil)
+
+	// A link was created at destination by a previous run
+	outside := filepath.Join(t.TempDir(), "authorized_keys")
+	require.NoError(t, os.WriteFile(outside, []byte("original"), 0o644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(c.config.Output, "file.txt")))
+
+	file := listed(c, srv.put("/src/file.txt", []byte("content"), time.Now().Add(-time.Hour)))
+	entry := c.download(context.Background(), file)
+	require.Equal(t, journal.EntryLevelSuccess, entry.Level, entry.Text)
+
+	content, err := os.ReadFile(outside)
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(content))
+
+	destfile, err := os.Lstat(filepath.Join(c.config.Output, "file.txt"))
+	require.NoError(t, err)
+	assert.True(t, destfile.Mode().IsRegular())
+}
@@ -0,0 +1,86 @@
+package grabber
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// progressWriter records when bytes were last written
+type progressWriter struct {
+	io.Writer
+	last int64
+}
+
+func newProgressWriter(w io.Writer) *progressWriter {
+	return &progressWriter{Writer: w, last: time.Now().UnixNano()}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	atomic.StoreInt64(&w.last, time.Now().UnixNano())
+	return n, err
+}
+
+func (w *progressWriter) idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&w.last)))
+}
+
+// watchIdle cancels a transfer once nothing has been written for longer
+// than timeout. The returned stop function reports whether the transfer
+// has been cancelled this way.
+func watchIdle(w *progressWriter, timeout time.Duration, cancel context.CancelFunc) (stop func() bool) {
+	interval := time.Second
+	if timeout < interval {
+		interval = timeout
+	}
+
+	var idled int32
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if w.idle() >= timeout {
+					atomic.StoreInt32(&idled, 1)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return func() bool {
+		close(done)
+		return atomic.LoadInt32(&idled) == 1
+	}
+}
+
+// transferContext returns the context of a file transfer bound to the file
+// timeout
+func (c *Client) transferContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if *c.config.FileTimeout > 0 {
+		return context.WithTimeout(ctx, *c.config.FileTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// transferError explains why a transfer has been interrupted
+func (c *Client) transferError(ctx context.Context, tctx context.Context, idled bool, err error) error {
+	switch {
+	case ctx.Err() != nil:
+		return errors.Wrap(ctx.Err(), "Transfer interrupted")
+	case idled:
+		return errors.Errorf("Transfer timeout, no progress for %s", *c.config.IdleTimeout)
+	case tctx.Err() == context.DeadlineExceeded:
+		return errors.Errorf("Transfer timeout, not completed within %s", *c.config.FileTimeout)
+	}
+	return err
+}
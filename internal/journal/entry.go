@@ -2,10 +2,12 @@ package journal
 
 // Entry represents a journal entry
 type Entry struct {
-	File   string      `json:"file,omitempty"`
-	Status EntryStatus `json:"status,omitempty"`
-	Level  EntryLevel  `json:"level,omitempty"`
-	Text   string      `json:"text,omitempty"`
+	File       string      `json:"file,omitempty"`
+	Status     EntryStatus `json:"status,omitempty"`
+	Level      EntryLevel  `json:"level,omitempty"`
+	Text       string      `json:"text,omitempty"`
+	Attempts   int         `json:"attempts,omitempty"`
+	ErrorClass string      `json:"errorClass,omitempty"`
 }
 
 // EntryLevel represents an entry kevek
@@ -22,13 +24,19 @@ const (
 type EntryStatus string
 
 const (
-	EntryStatusOutdated    = EntryStatus("Outdated file")
-	EntryStatusNotIncluded = EntryStatus("Not included")
-	EntryStatusExcluded    = EntryStatus("Excluded")
-	EntryStatusNeverDl     = EntryStatus("Never downloaded")
-	EntryStatusAlreadyDl   = EntryStatus("Already downloaded")
-	EntryStatusSizeDiff    = EntryStatus("Exists but size is different")
-	EntryStatusHashExists  = EntryStatus("Hash sum exists")
+	EntryStatusOutdated        = EntryStatus("Outdated file")
+	EntryStatusNotIncluded     = EntryStatus("Not included")
+	EntryStatusExcluded        = EntryStatus("Excluded")
+	EntryStatusPathNotIncluded = EntryStatus("Path not included")
+	EntryStatusPathExcluded    = EntryStatus("Path excluded")
+	EntryStatusTooSmall        = EntryStatus("Smaller than min size")
+	EntryStatusTooLarge        = EntryStatus("Larger than max size")
+	EntryStatusTooRecent       = EntryStatus("Younger than min age")
+	EntryStatusTooOld          = EntryStatus("Older than max age")
+	EntryStatusNeverDl         = EntryStatus("Never downloaded")
+	EntryStatusAlreadyDl       = EntryStatus("Already downloaded")
+	EntryStatusSizeDiff        = EntryStatus("Exists but size is different")
+	EntryStatusHashExists      = EntryStatus("Hash sum exists")
 )
 
 func (es *EntryStatus) IsSkipped() bool {
@@ -36,5 +44,11 @@ func (es *EntryStatus) IsSkipped() bool {
 		*es == EntryStatusHashExists ||
 		*es == EntryStatusOutdated ||
 		*es == EntryStatusNotIncluded ||
-		*es == EntryStatusExcluded
+		*es == EntryStatusExcluded ||
+		*es == EntryStatusPathNotIncluded ||
+		*es == EntryStatusPathExcluded ||
+		*es == EntryStatusTooSmall ||
+		*es == EntryStatusTooLarge ||
+		*es == EntryStatusTooRecent ||
+		*es == EntryStatusTooOld
 }
@@ -16,8 +16,9 @@ type Journal struct {
 		Error   int `json:"error,omitempty"`
 		Skip    int `json:"skip,omitempty"`
 	} `json:"count,omitempty"`
-	Status   string        `json:"status,omitempty"`
-	Duration time.Duration `json:"duration,omitempty"`
+	Status     string        `json:"status,omitempty"`
+	Reconnects int           `json:"reconnects,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
 }
 
 func (j Journal) MarshalJSON() ([]byte, error) {
@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/rs/zerolog"
+)
+
+// event holds a decoded zerolog JSON event
+type event struct {
+	message string
+	fields  map[string]string
+}
+
+func decodeEvent(p []byte) event {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(p, &raw); err != nil {
+		return event{message: string(p)}
+	}
+
+	evt := event{fields: make(map[string]string, len(raw))}
+	for key, value := range raw {
+		switch key {
+		case zerolog.LevelFieldName, zerolog.TimestampFieldName:
+			continue
+		case zerolog.MessageFieldName:
+			evt.message = fmt.Sprint(value)
+		default:
+			switch v := value.(type) {
+			case string:
+				evt.fields[key] = v
+			default:
+				b, _ := json.Marshal(v)
+				evt.fields[key] = string(b)
+			}
+		}
+	}
+
+	return evt
+}
+
+// keys returns field names sorted alphabetically
+func (e event) keys() []string {
+	keys := make([]string, 0, len(e.fields))
+	for key := range e.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
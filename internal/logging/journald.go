@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/journal"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// JournaldWriter is a zerolog writer sending events to the systemd journal
+type JournaldWriter struct {
+	tag string
+}
+
+// NewJournaldWriter creates a new journald writer
+func NewJournaldWriter(tag string) (*JournaldWriter, error) {
+	if !journal.Enabled() {
+		return nil, errors.New("Journald socket not available")
+	}
+	return &JournaldWriter{tag: tag}, nil
+}
+
+// Write implements io.Writer
+func (w *JournaldWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter
+func (w *JournaldWriter) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
+	evt := decodeEvent(p)
+
+	vars := map[string]string{
+		"SYSLOG_IDENTIFIER": w.tag,
+	}
+	for key, value := range evt.fields {
+		if name := journaldFieldName(key); len(name) > 0 {
+			vars[name] = value
+		}
+	}
+
+	if err = journal.Send(evt.message, journal.Priority(syslogSeverity(level)), vars); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// journaldFieldName converts a field name to a valid journal field name
+// (uppercase letters, digits and underscores not starting with an underscore)
+func journaldFieldName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	return strings.TrimLeft(name, "_")
+}
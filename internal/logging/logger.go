@@ -60,6 +60,22 @@ func Configure(cli config.Cli) {
 		w = zerolog.MultiLevelWriter(w, rwriter)
 	}
 
+	if len(cli.LogSyslog) > 0 {
+		swriter, err := NewSyslogWriter(cli.LogSyslog, cli.LogSyslogFacility, cli.LogSyslogTag)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Cannot create syslog writer")
+		}
+		w = zerolog.MultiLevelWriter(w, swriter)
+	}
+
+	if cli.LogJournald {
+		jwriter, err := NewJournaldWriter(cli.LogSyslogTag)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Cannot create journald writer")
+		}
+		w = zerolog.MultiLevelWriter(w, jwriter)
+	}
+
 	log.Logger = zerolog.New(w)
 	if cli.LogCaller {
 		log.Logger = log.Logger.With().Caller().Logger()
@@ -0,0 +1,210 @@
+package logging
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// syslogStructuredDataID is the SD-ID used for event fields in RFC5424 messages
+const syslogStructuredDataID = "ftpgrab@32473"
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// SyslogWriter is a zerolog writer sending RFC5424 messages to a syslog server
+type SyslogWriter struct {
+	mu       sync.Mutex
+	network  string
+	address  string
+	facility int
+	tag      string
+	hostname string
+	conn     net.Conn
+	stream   bool
+}
+
+// NewSyslogWriter creates a new syslog writer. The address is an URL using one
+// of the udp, tcp or unix schemes (e.g. udp://127.0.0.1:514, unix:///dev/log)
+func NewSyslogWriter(address string, facility string, tag string) (*SyslogWriter, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid syslog address")
+	}
+
+	w := &SyslogWriter{
+		network: u.Scheme,
+		tag:     tag,
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		w.address = u.Host
+	case "unix", "unixgram":
+		w.address = u.Path
+	default:
+		return nil, errors.Errorf("Unsupported syslog network %s", u.Scheme)
+	}
+
+	var ok bool
+	if w.facility, ok = syslogFacilities[strings.ToLower(facility)]; !ok {
+		return nil, errors.Errorf("Unknown syslog facility %s", facility)
+	}
+	if w.hostname, err = os.Hostname(); err != nil {
+		w.hostname = "-"
+	}
+
+	if err = w.connect(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *SyslogWriter) connect() error {
+	if w.conn != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+
+	var err error
+	if w.network == "unix" {
+		// Local syslog daemons usually listen on a datagram socket
+		if w.conn, err = net.Dial("unixgram", w.address); err == nil {
+			w.stream = false
+			return nil
+		}
+	}
+	if w.conn, err = net.DialTimeout(w.network, w.address, 5*time.Second); err != nil {
+		return err
+	}
+	w.stream = w.network == "tcp" || w.network == "unix"
+	return nil
+}
+
+// Write implements io.Writer
+func (w *SyslogWriter) Write(p []byte) (n int, err error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter
+func (w *SyslogWriter) WriteLevel(level zerolog.Level, p []byte) (n int, err error) {
+	msg := w.format(level, decodeEvent(p))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		if _, err = w.conn.Write(w.frame(msg)); err == nil {
+			return len(p), nil
+		}
+	}
+	if err = w.connect(); err != nil {
+		return 0, err
+	}
+	if _, err = w.conn.Write(w.frame(msg)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close closes the connection to the syslog server
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	return w.conn.Close()
+}
+
+func (w *SyslogWriter) format(level zerolog.Level, evt event) []byte {
+	var sd strings.Builder
+	if len(evt.fields) == 0 {
+		sd.WriteString("-")
+	} else {
+		sd.WriteString("[" + syslogStructuredDataID)
+		for _, key := range evt.keys() {
+			fmt.Fprintf(&sd, ` %s="%s"`, syslogParamName(key), syslogParamValue(evt.fields[key]))
+		}
+		sd.WriteString("]")
+	}
+
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s %d - %s %s",
+		w.facility*8+syslogSeverity(level),
+		time.Now().Format(time.RFC3339Nano),
+		w.hostname,
+		w.tag,
+		os.Getpid(),
+		sd.String(),
+		evt.message,
+	))
+}
+
+// frame prefixes messages sent over stream connections with their length
+// (RFC6587 octet counting), so messages may span several lines
+func (w *SyslogWriter) frame(msg []byte) []byte {
+	if !w.stream {
+		return msg
+	}
+	return append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+}
+
+func syslogSeverity(level zerolog.Level) int {
+	switch level {
+	case zerolog.PanicLevel:
+		return 0
+	case zerolog.FatalLevel:
+		return 2
+	case zerolog.ErrorLevel:
+		return 3
+	case zerolog.WarnLevel:
+		return 4
+	case zerolog.InfoLevel:
+		return 6
+	case zerolog.DebugLevel, zerolog.TraceLevel:
+		return 7
+	default:
+		return 5
+	}
+}
+
+func syslogParamName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+func syslogParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
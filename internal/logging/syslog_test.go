@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readFrame reads a message framed with octet counting
+func readFrame(r *bufio.Reader) (string, error) {
+	length, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	return string(msg), err
+}
+
+func TestSyslogStreamFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	w, err := NewSyslogWriter(fmt.Sprintf("tcp://%s", listener.Addr()), "daemon", "ftpgrab")
+	require.NoError(t, err)
+	defer w.Close()
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = w.WriteLevel(zerolog.ErrorLevel, []byte(`{"level":"error","message":"first line\nsecond line"}`+"\n"))
+	require.NoError(t, err)
+	_, err = w.WriteLevel(zerolog.InfoLevel, []byte(`{"level":"info","message":"next"}`+"\n"))
+	require.NoError(t, err)
+
+	r := bufio.NewReader(conn)
+	msg, err := readFrame(r)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(msg, "<27>1 "))
+	assert.True(t, strings.HasSuffix(msg, "first line\nsecond line"))
+
+	msg, err = readFrame(r)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(msg, "<30>1 "))
+	assert.True(t, strings.HasSuffix(msg, "next"))
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/crazy-max/ftpgrab/v7/internal/config"
 	"github.com/crazy-max/ftpgrab/v7/internal/journal"
 	"github.com/crazy-max/ftpgrab/v7/internal/notif/mail"
+	"github.com/crazy-max/ftpgrab/v7/internal/notif/mqtt"
 	"github.com/crazy-max/ftpgrab/v7/internal/notif/notifier"
 	"github.com/crazy-max/ftpgrab/v7/internal/notif/script"
 	"github.com/crazy-max/ftpgrab/v7/internal/notif/slack"
@@ -35,6 +36,9 @@ func New(cfg *config.Notif, meta config.Meta) (*Client, error) {
 	if cfg.Mail != nil {
 		c.notifiers = append(c.notifiers, mail.New(cfg.Mail, meta))
 	}
+	if cfg.MQTT != nil {
+		c.notifiers = append(c.notifiers, mqtt.New(cfg.MQTT, meta))
+	}
 	if cfg.Script != nil {
 		c.notifiers = append(c.notifiers, script.New(cfg.Script, meta))
 	}
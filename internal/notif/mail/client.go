@@ -1,19 +1,25 @@
 package mail
 
 import (
+	"bytes"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/crazy-max/ftpgrab/v7/internal/config"
 	"github.com/crazy-max/ftpgrab/v7/internal/journal"
 	"github.com/crazy-max/ftpgrab/v7/internal/notif/notifier"
 	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
-	"github.com/go-gomail/gomail"
 	"github.com/hako/durafmt"
 	"github.com/matcornic/hermes/v2"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	gomail "gopkg.in/mail.v2"
 )
 
 // Client represents an active mail notification object
@@ -54,13 +60,22 @@ func (c *Client) Send(jnl journal.Journal) error {
 		},
 	}
 
+	// Journal entries are either attached or rendered as a table
 	var entriesData [][]hermes.Entry
-	for _, entry := range jnl.Entries {
-		entriesData = append(entriesData, []hermes.Entry{
-			{Key: "Status", Value: string(entry.Level)},
-			{Key: "Info", Value: entry.Text},
-			{Key: "File", Value: entry.File},
-		})
+	var attachment []byte
+	var err error
+	if len(c.cfg.Attachment) > 0 {
+		if attachment, err = c.attachment(jnl); err != nil {
+			return errors.Wrap(err, "Cannot generate journal attachment for email notification")
+		}
+	} else {
+		for _, entry := range jnl.Entries {
+			entriesData = append(entriesData, []hermes.Entry{
+				{Key: "Status", Value: string(entry.Level)},
+				{Key: "Info", Value: entry.Text},
+				{Key: "File", Value: entry.File},
+			})
+		}
 	}
 
 	email := hermes.Email{
@@ -102,7 +117,13 @@ func (c *Client) Send(jnl journal.Journal) error {
 
 	msg := gomail.NewMessage()
 	msg.SetHeader("From", fmt.Sprintf("%s <%s>", c.meta.Name, c.cfg.From))
-	msg.SetHeader("To", c.cfg.To)
+	msg.SetHeader("To", c.cfg.To...)
+	if len(c.cfg.Cc) > 0 {
+		msg.SetHeader("Cc", c.cfg.Cc...)
+	}
+	if len(c.cfg.Bcc) > 0 {
+		msg.SetHeader("Bcc", c.cfg.Bcc...)
+	}
 	msg.SetHeader("Subject", fmt.Sprintf("%s report for %s on %s",
 		c.meta.Name,
 		jnl.ServerHost,
@@ -110,11 +131,22 @@ func (c *Client) Send(jnl journal.Journal) error {
 	))
 	msg.SetBody("text/plain", textpart)
 	msg.AddAlternative("text/html", htmlpart)
+	if attachment != nil {
+		msg.AttachReader(fmt.Sprintf("journal.%s", c.cfg.Attachment), bytes.NewReader(attachment))
+	}
 
-	var tlsConfig *tls.Config
-	if *c.cfg.InsecureSkipVerify {
-		tlsConfig = &tls.Config{
-			InsecureSkipVerify: *c.cfg.InsecureSkipVerify,
+	tlsConfig := &tls.Config{
+		ServerName:         c.cfg.Host,
+		InsecureSkipVerify: *c.cfg.InsecureSkipVerify,
+	}
+	if len(c.cfg.CAFile) > 0 {
+		caCert, err := os.ReadFile(c.cfg.CAFile)
+		if err != nil {
+			return errors.Wrap(err, "Cannot read CA file")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return errors.Errorf("No valid certificate found in CA file %s", c.cfg.CAFile)
 		}
 	}
 
@@ -128,13 +160,59 @@ func (c *Client) Send(jnl journal.Journal) error {
 	}
 
 	dialer := &gomail.Dialer{
-		Host:      c.cfg.Host,
-		Port:      c.cfg.Port,
-		Username:  username,
-		Password:  password,
-		SSL:       *c.cfg.SSL,
-		TLSConfig: tlsConfig,
+		Host:           c.cfg.Host,
+		Port:           c.cfg.Port,
+		Username:       username,
+		Password:       password,
+		SSL:            *c.cfg.SSL,
+		TLSConfig:      tlsConfig,
+		StartTLSPolicy: startTLSPolicy(c.cfg.StartTLS),
+		Timeout:        10 * time.Second,
 	}
 
 	return dialer.DialAndSend(msg)
 }
+
+func (c *Client) attachment(jnl journal.Journal) ([]byte, error) {
+	var buf bytes.Buffer
+	switch c.cfg.Attachment {
+	case "json":
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(jnl); err != nil {
+			return nil, err
+		}
+	case "csv":
+		if err := writeCSV(&buf, jnl); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("Unknown attachment format %s", c.cfg.Attachment)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCSV(w io.Writer, jnl journal.Journal) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"file", "status", "level", "text"}); err != nil {
+		return err
+	}
+	for _, entry := range jnl.Entries {
+		if err := cw.Write([]string{entry.File, string(entry.Status), string(entry.Level), entry.Text}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func startTLSPolicy(mode string) gomail.StartTLSPolicy {
+	switch mode {
+	case "mandatory":
+		return gomail.MandatoryStartTLS
+	case "none":
+		return gomail.NoStartTLS
+	default:
+		return gomail.OpportunisticStartTLS
+	}
+}
@@ -0,0 +1,255 @@
+package mail
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gomail "gopkg.in/mail.v2"
+)
+
+func testJournal() journal.Journal {
+	jnl := journal.Journal{
+		ServerHost: "10.0.0.1",
+		Entries: []journal.Entry{
+			{
+				File:   "/src/foo.zip",
+				Status: journal.EntryStatusNeverDl,
+				Level:  journal.EntryLevelSuccess,
+				Text:   "1.049MB successfully downloaded",
+			},
+			{
+				File:   "/src/bar, \"quoted\".txt",
+				Status: journal.EntryStatusExcluded,
+				Level:  journal.EntryLevelSkip,
+			},
+		},
+		Duration: 3 * time.Second,
+	}
+	jnl.Count.Success = 1
+	jnl.Count.Skip = 1
+	return jnl
+}
+
+func TestAttachmentCSV(t *testing.T) {
+	c := &Client{cfg: &config.NotifMail{Attachment: "csv"}}
+	data, err := c.attachment(testJournal())
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"file", "status", "level", "text"},
+		{"/src/foo.zip", string(journal.EntryStatusNeverDl), string(journal.EntryLevelSuccess), "1.049MB successfully downloaded"},
+		{"/src/bar, \"quoted\".txt", string(journal.EntryStatusExcluded), string(journal.EntryLevelSkip), ""},
+	}, records)
+}
+
+func TestAttachmentJSON(t *testing.T) {
+	c := &Client{cfg: &config.NotifMail{Attachment: "json"}}
+	data, err := c.attachment(testJournal())
+	require.NoError(t, err)
+
+	var jnl struct {
+		Entries []journal.Entry `json:"entries"`
+		Count   struct {
+			Success int `json:"success"`
+			Skip    int `json:"skip"`
+		} `json:"count"`
+		Duration string `json:"duration"`
+	}
+	require.NoError(t, json.Unmarshal(data, &jnl))
+	assert.Equal(t, testJournal().Entries, jnl.Entries)
+	assert.Equal(t, 1, jnl.Count.Success)
+	assert.Equal(t, 1, jnl.Count.Skip)
+	assert.Equal(t, "3 seconds", jnl.Duration)
+}
+
+func TestAttachmentUnknown(t *testing.T) {
+	c := &Client{cfg: &config.NotifMail{Attachment: "xml"}}
+	_, err := c.attachment(testJournal())
+	assert.Error(t, err)
+}
+
+func TestStartTLSPolicy(t *testing.T) {
+	assert.Equal(t, gomail.MandatoryStartTLS, startTLSPolicy("mandatory"))
+	assert.Equal(t, gomail.OpportunisticStartTLS, startTLSPolicy("opportunistic"))
+	assert.Equal(t, gomail.StartTLSPolicy(gomail.NoStartTLS), startTLSPolicy("none"))
+}
+
+// smtpServer is a plain SMTP server recording the envelope and data of
+// messages. It does not offer STARTTLS.
+type smtpServer struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	from string
+	rcpt []string
+	data string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpServer{ln: ln}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		_, _ = fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			s.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func newTestClient(t *testing.T, srv *smtpServer, setup func(cfg *config.NotifMail)) *Client {
+	host, port, err := net.SplitHostPort(srv.ln.Addr().String())
+	require.NoError(t, err)
+	cfg := (&config.NotifMail{}).GetDefaults()
+	cfg.Host = host
+	cfg.Port, err = strconv.Atoi(port)
+	require.NoError(t, err)
+	cfg.From = "ftpgrab@example.com"
+	cfg.To = []string{"webmaster@example.com"}
+	if setup != nil {
+		setup(cfg)
+	}
+	return &Client{
+		cfg: cfg,
+		meta: config.Meta{
+			Name:     "FTPGrab",
+			Version:  "1.0.0",
+			Hostname: "my-computer",
+		},
+	}
+}
+
+func TestSendRecipients(t *testing.T) {
+	srv := newSMTPServer(t)
+	err := newTestClient(t, srv, func(cfg *config.NotifMail) {
+		cfg.To = []string{"webmaster@example.com", "admin@example.com"}
+		cfg.Cc = []string{"foo@example.com"}
+		cfg.Bcc = []string{"bar@example.com"}
+	}).Send(testJournal())
+	require.NoError(t, err)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Equal(t, "ftpgrab@example.com", srv.from)
+	assert.ElementsMatch(t, []string{
+		"webmaster@example.com",
+		"admin@example.com",
+		"foo@example.com",
+		"bar@example.com",
+	}, srv.rcpt)
+	assert.Contains(t, srv.data, "To: webmaster@example.com, admin@example.com\r\n")
+	assert.Contains(t, srv.data, "Cc: foo@example.com\r\n")
+	assert.NotContains(t, srv.data, "bar@example.com")
+	assert.Contains(t, srv.data, "Subject: FTPGrab report for 10.0.0.1 on my-computer\r\n")
+}
+
+func TestSendAttachment(t *testing.T) {
+	srv := newSMTPServer(t)
+	err := newTestClient(t, srv, func(cfg *config.NotifMail) {
+		cfg.Attachment = "csv"
+	}).Send(testJournal())
+	require.NoError(t, err)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.Contains(t, srv.data, `filename="journal.csv"`)
+}
+
+func TestSendStartTLS(t *testing.T) {
+	cases := []struct {
+		startTLS string
+		wantErr  bool
+	}{
+		{startTLS: "opportunistic"},
+		{startTLS: "none"},
+		{startTLS: "mandatory", wantErr: true},
+	}
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.startTLS, func(t *testing.T) {
+			srv := newSMTPServer(t)
+			err := newTestClient(t, srv, func(cfg *config.NotifMail) {
+				cfg.StartTLS = tt.startTLS
+			}).Send(testJournal())
+			if tt.wantErr {
+				require.Error(t, err)
+				srv.mu.Lock()
+				defer srv.mu.Unlock()
+				assert.Empty(t, srv.rcpt)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/crazy-max/ftpgrab/v7/internal/notif/notifier"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Client represents an active mqtt notification object
+type Client struct {
+	*notifier.Notifier
+	cfg  *config.NotifMQTT
+	meta config.Meta
+}
+
+// New creates a new mqtt notification instance
+func New(cfg *config.NotifMQTT, meta config.Meta) notifier.Notifier {
+	return notifier.Notifier{
+		Handler: &Client{
+			cfg:  cfg,
+			meta: meta,
+		},
+	}
+}
+
+// Name returns notifier's name
+func (c *Client) Name() string {
+	return "mqtt"
+}
+
+// Send creates and publishes a mqtt notification with journal entries
+func (c *Client) Send(jnl journal.Journal) error {
+	username, err := utl.GetSecret(c.cfg.Username, c.cfg.UsernameFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot retrieve username secret for mqtt notifier")
+	}
+	password, err := utl.GetSecret(c.cfg.Password, c.cfg.PasswordFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot retrieve password secret for mqtt notifier")
+	}
+
+	scheme := "tcp"
+	if *c.cfg.TLS {
+		scheme = "ssl"
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(fmt.Sprintf("%s://%s:%d", scheme, c.cfg.Host, c.cfg.Port)).
+		SetClientID(c.cfg.ClientID).
+		SetUsername(username).
+		SetPassword(password).
+		SetConnectTimeout(*c.cfg.Timeout).
+		SetWriteTimeout(*c.cfg.Timeout).
+		SetAutoReconnect(false)
+	if *c.cfg.TLS {
+		opts.SetTLSConfig(&tls.Config{
+			ServerName:         c.cfg.Host,
+			InsecureSkipVerify: *c.cfg.InsecureSkipVerify,
+		})
+	}
+
+	client := mqtt.NewClient(opts)
+	if err := c.wait(client.Connect()); err != nil {
+		return errors.Wrap(err, "Cannot connect to MQTT broker")
+	}
+	defer client.Disconnect(250)
+
+	body, err := json.Marshal(notifier.NewPayload(jnl, c.meta))
+	if err != nil {
+		return err
+	}
+	if err := c.wait(client.Publish(c.cfg.Topic, byte(c.cfg.QoS), *c.cfg.Retain, body)); err != nil {
+		return errors.Wrapf(err, "Cannot publish journal to topic %s", c.cfg.Topic)
+	}
+
+	if len(c.cfg.EntriesTopic) == 0 {
+		return nil
+	}
+
+	entriesTopic := path.Join(c.cfg.Topic, c.cfg.EntriesTopic)
+	for _, entry := range jnl.Entries {
+		entryBody, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := c.wait(client.Publish(entriesTopic, byte(c.cfg.QoS), false, entryBody)); err != nil {
+			return errors.Wrapf(err, "Cannot publish entry to topic %s", entriesTopic)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) wait(token mqtt.Token) error {
+	if !token.WaitTimeout(*c.cfg.Timeout) {
+		return errors.Errorf("timed out after %s", c.cfg.Timeout.String())
+	}
+	return token.Error()
+}
@@ -0,0 +1,24 @@
+package notifier
+
+import (
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+)
+
+// Payload represents the JSON body sent by webhook-like notifiers
+type Payload struct {
+	Version  string          `json:"ftpgrab_version,omitempty"`
+	ServerIP string          `json:"server_ip,omitempty"`
+	Dest     string          `json:"dest_hostname,omitempty"`
+	Journal  journal.Journal `json:"journal,omitempty"`
+}
+
+// NewPayload creates a new payload from journal entries
+func NewPayload(jnl journal.Journal, meta config.Meta) Payload {
+	return Payload{
+		Version:  meta.Version,
+		ServerIP: jnl.ServerHost,
+		Dest:     meta.Hostname,
+		Journal:  jnl,
+	}
+}
@@ -64,12 +64,15 @@ func (c *Client) Send(jnl journal.Journal) error {
 			fmt.Sprintf("FTPGRAB_JOURNAL_ENTRIES[%d]_STATUS=%s", idx, string(entry.Status)),
 			fmt.Sprintf("FTPGRAB_JOURNAL_ENTRIES[%d]_LEVEL=%s", idx, string(entry.Level)),
 			fmt.Sprintf("FTPGRAB_JOURNAL_ENTRIES[%d]_TEXT=%s", idx, entry.Text),
+			fmt.Sprintf("FTPGRAB_JOURNAL_ENTRIES[%d]_ATTEMPTS=%d", idx, entry.Attempts),
+			fmt.Sprintf("FTPGRAB_JOURNAL_ENTRIES[%d]_ERROR_CLASS=%s", idx, entry.ErrorClass),
 		}...)
 	}
 	cmd.Env = append(cmd.Env, []string{
 		fmt.Sprintf("FTPGRAB_JOURNAL_COUNT_SUCCESS=%d", jnl.Count.Success),
 		fmt.Sprintf("FTPGRAB_JOURNAL_COUNT_ERROR=%d", jnl.Count.Error),
 		fmt.Sprintf("FTPGRAB_JOURNAL_COUNT_SKIP=%d", jnl.Count.Skip),
+		fmt.Sprintf("FTPGRAB_JOURNAL_RECONNECTS=%d", jnl.Reconnects),
 		fmt.Sprintf("FTPGRAB_JOURNAL_DURATION=%s", durafmt.ParseShort(jnl.Duration).String()),
 	}...)
 
@@ -2,14 +2,27 @@ package webhook
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"net/http"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/crazy-max/ftpgrab/v7/internal/config"
 	"github.com/crazy-max/ftpgrab/v7/internal/journal"
 	"github.com/crazy-max/ftpgrab/v7/internal/notif/notifier"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 )
 
+// SignatureHeader is the header holding the HMAC-SHA256 signature of the body
+const SignatureHeader = "X-FTPGrab-Signature"
+
 // Client represents an active webhook notification object
 type Client struct {
 	*notifier.Notifier
@@ -38,24 +51,54 @@ func (c *Client) Send(jnl journal.Journal) error {
 		Timeout: *c.cfg.Timeout,
 	}
 
-	body, err := json.Marshal(struct {
-		Version  string          `json:"ftpgrab_version,omitempty"`
-		ServerIP string          `json:"server_ip,omitempty"`
-		Dest     string          `json:"dest_hostname,omitempty"`
-		Journal  journal.Journal `json:"journal,omitempty"`
-	}{
-		Version:  c.meta.Version,
-		ServerIP: jnl.ServerHost,
-		Dest:     c.meta.Hostname,
-		Journal:  jnl,
-	})
+	body, err := c.body(jnl)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest(c.cfg.Method, c.cfg.Endpoint, bytes.NewBuffer(body))
+	secret, err := utl.GetSecret(c.cfg.Secret, c.cfg.SecretFile)
 	if err != nil {
-		return err
+		log.Warn().Err(err).Msg("Cannot retrieve secret for webhook notifier")
+	}
+
+	delay := *c.cfg.RetryDelay
+	for retry := 0; ; retry++ {
+		retryable, err := c.do(&hc, body, secret)
+		if err == nil {
+			return nil
+		} else if !retryable || retry >= c.cfg.Retry {
+			return err
+		}
+		log.Warn().Err(err).Msgf("Webhook request failed, retry %d/%d in %s", retry+1, c.cfg.Retry, delay)
+		time.Sleep(delay)
+		if delay *= 2; delay > *c.cfg.RetryMaxDelay {
+			delay = *c.cfg.RetryMaxDelay
+		}
+	}
+}
+
+func (c *Client) body(jnl journal.Journal) ([]byte, error) {
+	payload := notifier.NewPayload(jnl, c.meta)
+	if len(c.cfg.Template) == 0 {
+		return json.Marshal(payload)
+	}
+
+	tpl, err := template.New("body").Parse(c.cfg.Template)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot parse webhook body template")
+	}
+	var buf bytes.Buffer
+	if err = tpl.Execute(&buf, payload); err != nil {
+		return nil, errors.Wrap(err, "Cannot execute webhook body template")
+	}
+	return buf.Bytes(), nil
+}
+
+// do sends the request and reports whether a failure is worth retrying
+func (c *Client) do(hc *http.Client, body []byte, secret string) (bool, error) {
+	req, err := http.NewRequest(c.cfg.Method, c.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
 	}
 
 	if len(c.cfg.Headers) > 0 {
@@ -65,7 +108,23 @@ func (c *Client) Send(jnl journal.Journal) error {
 	}
 
 	req.Header.Set("User-Agent", c.meta.UserAgent)
+	if len(secret) > 0 {
+		mac := hmac.New(sha256.New, []byte(secret))
+		_, _ = mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
 
-	_, err = hc.Do(req)
-	return err
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = errors.Errorf("Unexpected HTTP status %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
 }
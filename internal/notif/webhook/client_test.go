@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/journal"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(endpoint string, setup func(cfg *config.NotifWebhook)) *Client {
+	cfg := (&config.NotifWebhook{}).GetDefaults()
+	cfg.Endpoint = endpoint
+	cfg.Method = http.MethodPost
+	cfg.RetryDelay = utl.NewDuration(time.Millisecond)
+	cfg.RetryMaxDelay = utl.NewDuration(2 * time.Millisecond)
+	if setup != nil {
+		setup(cfg)
+	}
+	return &Client{
+		cfg: cfg,
+		meta: config.Meta{
+			Version:   "1.0.0",
+			UserAgent: "ftpgrab/1.0.0",
+			Hostname:  "my-computer",
+		},
+	}
+}
+
+func testJournal() journal.Journal {
+	jnl := journal.Journal{
+		ServerHost: "10.0.0.1",
+		Entries: []journal.Entry{{
+			File:   "/src/foo.zip",
+			Status: journal.EntryStatusNeverDl,
+			Level:  journal.EntryLevelSuccess,
+		}},
+	}
+	jnl.Count.Success = 1
+	return jnl
+}
+
+func TestSendStatus(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   int
+		attempts int32
+		wantErr  bool
+	}{
+		{name: "ok", status: http.StatusOK, attempts: 1},
+		{name: "no content", status: http.StatusNoContent, attempts: 1},
+		{name: "client error", status: http.StatusBadRequest, attempts: 1, wantErr: true},
+		{name: "redirect", status: http.StatusNotModified, attempts: 1, wantErr: true},
+		{name: "too many requests", status: http.StatusTooManyRequests, attempts: 4, wantErr: true},
+		{name: "server error", status: http.StatusBadGateway, attempts: 4, wantErr: true},
+	}
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			err := newTestClient(srv.URL, nil).Send(testJournal())
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.attempts, atomic.LoadInt32(&attempts))
+		})
+	}
+}
+
+func TestSendRetryRecovers(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	require.NoError(t, newTestClient(srv.URL, nil).Send(testJournal()))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestSendNoRetry(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	err := newTestClient(srv.URL, func(cfg *config.NotifWebhook) {
+		cfg.Retry = 0
+	}).Send(testJournal())
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestSendErrorBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, "  invalid token\n")
+	}))
+	defer srv.Close()
+
+	err := newTestClient(srv.URL, nil).Send(testJournal())
+	require.Error(t, err)
+	assert.Equal(t, "Unexpected HTTP status 401 Unauthorized: invalid token", err.Error())
+}
+
+func TestSendSignature(t *testing.T) {
+	var body []byte
+	var signature, userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		userAgent = r.Header.Get("User-Agent")
+	}))
+	defer srv.Close()
+
+	require.NoError(t, newTestClient(srv.URL, func(cfg *config.NotifWebhook) {
+		cfg.Secret = "mysecret"
+	}).Send(testJournal()))
+
+	mac := hmac.New(sha256.New, []byte("mysecret"))
+	_, _ = mac.Write(body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+	assert.Equal(t, "ftpgrab/1.0.0", userAgent)
+	assert.Contains(t, string(body), `"server_ip":"10.0.0.1"`)
+}
+
+func TestSendNoSignature(t *testing.T) {
+	var signed bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, signed = r.Header[SignatureHeader]
+	}))
+	defer srv.Close()
+
+	require.NoError(t, newTestClient(srv.URL, nil).Send(testJournal()))
+	assert.False(t, signed)
+}
+
+func TestSendTemplate(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer srv.Close()
+
+	require.NoError(t, newTestClient(srv.URL, func(cfg *config.NotifWebhook) {
+		cfg.Template = `{"text": "{{ .Journal.Count.Success }} file(s) downloaded from {{ .ServerIP }} on {{ .Dest }}"}`
+	}).Send(testJournal()))
+	assert.Equal(t, `{"text": "1 file(s) downloaded from 10.0.0.1 on my-computer"}`, body)
+}
+
+func TestSendTemplateError(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+	}))
+	defer srv.Close()
+
+	err := newTestClient(srv.URL, func(cfg *config.NotifWebhook) {
+		cfg.Template = `{{ .Unknown }}`
+	}).Send(testJournal())
+	require.Error(t, err)
+	assert.Zero(t, atomic.LoadInt32(&attempts))
+}
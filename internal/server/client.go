@@ -1,21 +1,263 @@
 package server
 
 import (
+	"context"
 	"io"
 	"os"
+	"path"
+	"sync"
+	"time"
 
 	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 )
 
-// Handler is a server interface
+const (
+	// MaxSymlinks is the maximum number of symbolic links followed to
+	// resolve a path
+	MaxSymlinks = 40
+)
+
+// Handler is a server interface. Operations are interrupted by closing the
+// connection if their context is done before they complete.
 type Handler interface {
 	Common() config.ServerCommon
-	ReadDir(source string) ([]os.FileInfo, error)
-	Retrieve(path string, dest io.Writer) error
+	ReadDir(ctx context.Context, source string) ([]os.FileInfo, error)
+	Stat(ctx context.Context, path string) (os.FileInfo, error)
+	ReadLink(ctx context.Context, path string) (string, error)
+	ModTime(ctx context.Context, path string) (time.Time, error)
+	Retrieve(ctx context.Context, path string, dest io.Writer) error
+	Remove(ctx context.Context, path string) error
+	ConnLost(err error) bool
+	Classify(err error) ErrorClass
 	Close() error
 }
 
-// Client represents an active server object
+// ErrorClass tells whether an operation failing with an error is worth a
+// retry
+type ErrorClass string
+
+const (
+	ErrorTransient = ErrorClass("transient")
+	ErrorPermanent = ErrorClass("permanent")
+)
+
+// Client represents an active server object. It reconnects to the server
+// if the connection is lost.
 type Client struct {
-	Handler
+	cfg        *config.ServerReconnect
+	dial       func() (Handler, error)
+	handler    Handler
+	mu         sync.Mutex
+	gen        int
+	broken     bool
+	lost       error
+	reconnects int
+}
+
+// New connects to a server using dial, which is also used to reconnect
+// as configured by cfg
+func New(cfg *config.ServerReconnect, dial func() (Handler, error)) (*Client, error) {
+	handler, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{cfg: cfg, dial: dial, handler: handler}, nil
+}
+
+// Common return common configuration
+func (c *Client) Common() config.ServerCommon {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.handler.Common()
+}
+
+// ReadDir fetches the contents of a directory, returning a list of os.FileInfo's
+func (c *Client) ReadDir(ctx context.Context, source string) (entries []os.FileInfo, err error) {
+	err = c.do(ctx, true, func(handler Handler) (err error) {
+		entries, err = handler.ReadDir(ctx, source)
+		return err
+	})
+	return entries, err
+}
+
+// Stat returns the os.FileInfo of a file or directory, following
+// symbolic links
+func (c *Client) Stat(ctx context.Context, path string) (info os.FileInfo, err error) {
+	err = c.do(ctx, true, func(handler Handler) (err error) {
+		info, err = handler.Stat(ctx, path)
+		return err
+	})
+	return info, err
+}
+
+// ReadLink returns the target of a symbolic link
+func (c *Client) ReadLink(ctx context.Context, path string) (target string, err error) {
+	err = c.do(ctx, true, func(handler Handler) (err error) {
+		target, err = handler.ReadLink(ctx, path)
+		return err
+	})
+	return target, err
+}
+
+// ModTime returns the precise modification time of a file, or a zero time
+// if the one returned by ReadDir is already precise
+func (c *Client) ModTime(ctx context.Context, path string) (mtime time.Time, err error) {
+	err = c.do(ctx, true, func(handler Handler) (err error) {
+		mtime, err = handler.ModTime(ctx, path)
+		return err
+	})
+	return mtime, err
+}
+
+// Retrieve file "path" from server and write bytes to "dest". It is not
+// run again on a new connection as bytes may have already been written.
+func (c *Client) Retrieve(ctx context.Context, path string, dest io.Writer) error {
+	return c.do(ctx, false, func(handler Handler) error {
+		return handler.Retrieve(ctx, path, dest)
+	})
+}
+
+// Remove deletes file "path" from server
+func (c *Client) Remove(ctx context.Context, path string) error {
+	return c.do(ctx, false, func(handler Handler) error {
+		return handler.Remove(ctx, path)
+	})
+}
+
+// Classify returns the class of an error returned by an operation
+func (c *Client) Classify(err error) ErrorClass {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lost != nil && errors.Is(err, c.lost) {
+		return ErrorPermanent
+	}
+	return c.handler.Classify(err)
+}
+
+// Reconnects returns the number of times the connection has been
+// reestablished
+func (c *Client) Reconnects() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reconnects
+}
+
+// Close closes server connection
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.broken || c.lost != nil {
+		_ = c.handler.Close()
+		return nil
+	}
+	return c.handler.Close()
+}
+
+// do runs op on the current connection. Idempotent operations are run
+// again once if the connection is lost while running them.
+func (c *Client) do(ctx context.Context, idempotent bool, op func(handler Handler) error) error {
+	for attempt := 1; ; attempt++ {
+		handler, gen, err := c.conn(ctx)
+		if err != nil {
+			return err
+		}
+		err = op(handler)
+		if !c.check(handler, gen, err) || !idempotent || attempt > 1 || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// conn returns the current connection, reconnecting first if it is lost
+func (c *Client) conn(ctx context.Context) (Handler, int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lost != nil {
+		return nil, 0, c.lost
+	} else if c.broken {
+		if err := c.reconnect(ctx); err != nil {
+			return nil, 0, err
+		}
+	}
+	return c.handler, c.gen, nil
+}
+
+// check flags the connection as lost if err is caused by it
+func (c *Client) check(handler Handler, gen int, err error) bool {
+	if err == nil || !handler.ConnLost(err) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen == c.gen && !c.broken {
+		log.Warn().Err(err).Msg("Connection to server lost")
+		c.broken = true
+	}
+	return true
+}
+
+// reconnect dials the server again with an increasing delay between
+// attempts. Must be called while holding the lock.
+func (c *Client) reconnect(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_ = c.handler.Close()
+
+	err := errors.New("Reconnection disabled")
+	delay := *c.cfg.Delay
+	for attempt := 1; attempt <= c.cfg.Attempts; attempt++ {
+		var handler Handler
+		if handler, err = c.dial(); err == nil {
+			log.Info().Msgf("Reconnected to server (attempt %d/%d)", attempt, c.cfg.Attempts)
+			c.handler, c.broken = handler, false
+			c.gen++
+			c.reconnects++
+			return nil
+		}
+		log.Warn().Err(err).Msgf("Cannot reconnect to server, attempt %d/%d", attempt, c.cfg.Attempts)
+		if attempt == c.cfg.Attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > *c.cfg.MaxDelay {
+			delay = *c.cfg.MaxDelay
+		}
+	}
+
+	c.lost = errors.Wrap(err, "Cannot reconnect to server")
+	return c.lost
+}
+
+// ResolveLink returns the path targeted by a symbolic link located in dir
+func ResolveLink(dir string, target string) string {
+	if path.IsAbs(target) {
+		return path.Clean(target)
+	}
+	return path.Join(dir, target)
+}
+
+// Watch calls abort if ctx is done before the returned stop function is
+// called. It is used to interrupt blocking network operations.
+func Watch(ctx context.Context, abort func()) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			abort()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+	}
 }
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	errLost = errors.New("connection lost")
+	errFile = errors.New("no such file")
+)
+
+// testHandler is a connection to a fake server. Operations fail with
+// errLost once the connection is lost.
+type testHandler struct {
+	server *testServer
+	mu     sync.Mutex
+	lost   bool
+	closed bool
+}
+
+func (h *testHandler) Common() config.ServerCommon {
+	return config.ServerCommon{}
+}
+
+func (h *testHandler) ReadDir(ctx context.Context, source string) ([]os.FileInfo, error) {
+	if err := h.op(); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (h *testHandler) Stat(ctx context.Context, path string) (os.FileInfo, error) {
+	if err := h.op(); err != nil {
+		return nil, err
+	}
+	if path == "/missing" {
+		return nil, errFile
+	}
+	return nil, nil
+}
+
+func (h *testHandler) ReadLink(ctx context.Context, path string) (string, error) {
+	return "", h.op()
+}
+
+func (h *testHandler) ModTime(ctx context.Context, path string) (time.Time, error) {
+	return time.Time{}, h.op()
+}
+
+func (h *testHandler) Retrieve(ctx context.Context, path string, dest io.Writer) error {
+	if err := h.op(); err != nil {
+		return err
+	}
+	_, _ = io.WriteString(dest, "partial")
+	h.server.drop()
+	return h.op()
+}
+
+func (h *testHandler) Remove(ctx context.Context, path string) error {
+	return h.op()
+}
+
+func (h *testHandler) ConnLost(err error) bool {
+	return errors.Is(err, errLost)
+}
+
+func (h *testHandler) Classify(err error) ErrorClass {
+	return ErrorTransient
+}
+
+func (h *testHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	return nil
+}
+
+// op counts an operation run on the connection
+func (h *testHandler) op() error {
+	h.server.mu.Lock()
+	defer h.server.mu.Unlock()
+	h.server.ops++
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.lost {
+		return errLost
+	}
+	return nil
+}
+
+// testServer is a fake server accepting connections until failDials is
+// set
+type testServer struct {
+	mu        sync.Mutex
+	conns     []*testHandler
+	ops       int
+	dials     int
+	failDials bool
+}
+
+func (s *testServer) dial() (Handler, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dials++
+	if s.failDials {
+		return nil, errors.New("connection refused")
+	}
+	h := &testHandler{server: s}
+	s.conns = append(s.conns, h)
+	return h, nil
+}
+
+// drop loses the current connection
+func (s *testServer) drop() {
+	h := s.conns[len(s.conns)-1]
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lost = true
+}
+
+func newTestClient(t *testing.T, srv *testServer, attempts int) *Client {
+	t.Helper()
+
+	client, err := New(&config.ServerReconnect{
+		Attempts: attempts,
+		Delay:    utl.NewDuration(time.Millisecond),
+		MaxDelay: utl.NewDuration(2 * time.Millisecond),
+	}, srv.dial)
+	require.NoError(t, err)
+	return client
+}
+
+func TestReconnectResume(t *testing.T) {
+	srv := &testServer{}
+	c := newTestClient(t, srv, 3)
+
+	_, err := c.Stat(context.Background(), "/foo")
+	require.NoError(t, err)
+
+	// The lost operation is run again on a new connection
+	srv.drop()
+	_, err = c.Stat(context.Background(), "/foo")
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Reconnects())
+	assert.Equal(t, 2, srv.dials)
+	assert.Equal(t, 3, srv.ops)
+	assert.True(t, srv.conns[0].closed)
+
+	// Next operations use the new connection
+	_, err = c.ReadDir(context.Background(), "/")
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Reconnects())
+	assert.Equal(t, 4, srv.ops)
+}
+
+func TestReconnectOtherError(t *testing.T) {
+	srv := &testServer{}
+	c := newTestClient(t, srv, 3)
+
+	_, err := c.Stat(context.Background(), "/missing")
+	require.ErrorIs(t, err, errFile)
+	assert.Zero(t, c.Reconnects())
+	assert.Equal(t, 1, srv.dials)
+	assert.Equal(t, 1, srv.ops)
+}
+
+func TestReconnectRetrieve(t *testing.T) {
+	srv := &testServer{}
+	c := newTestClient(t, srv, 3)
+
+	// A retrieval is not run again as bytes have already been written
+	var dest bytes.Buffer
+	err := c.Retrieve(context.Background(), "/foo", &dest)
+	require.ErrorIs(t, err, errLost)
+	assert.Equal(t, "partial", dest.String())
+	assert.Equal(t, 1, srv.dials)
+	assert.Equal(t, ErrorTransient, c.Classify(err))
+
+	// The next operation reconnects first
+	require.NoError(t, c.Remove(context.Background(), "/foo"))
+	assert.Equal(t, 1, c.Reconnects())
+	assert.Equal(t, 2, srv.dials)
+}
+
+func TestReconnectAttempts(t *testing.T) {
+	srv := &testServer{}
+	c := newTestClient(t, srv, 3)
+
+	srv.drop()
+	srv.failDials = true
+	_, err := c.Stat(context.Background(), "/foo")
+	require.Error(t, err)
+	assert.Equal(t, ErrorPermanent, c.Classify(err))
+	assert.Zero(t, c.Reconnects())
+	assert.Equal(t, 4, srv.dials)
+
+	// The connection is not dialed again once attempts are exhausted
+	_, err = c.ReadDir(context.Background(), "/")
+	require.Error(t, err)
+	assert.Equal(t, ErrorPermanent, c.Classify(err))
+	assert.Equal(t, 4, srv.dials)
+	require.NoError(t, c.Close())
+}
+
+func TestReconnectDisabled(t *testing.T) {
+	srv := &testServer{}
+	c := newTestClient(t, srv, 0)
+
+	srv.drop()
+	_, err := c.Stat(context.Background(), "/foo")
+	require.Error(t, err)
+	assert.Equal(t, ErrorPermanent, c.Classify(err))
+	assert.Equal(t, 1, srv.dials)
+}
+
+func TestReconnectCanceled(t *testing.T) {
+	srv := &testServer{}
+	c := newTestClient(t, srv, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	srv.drop()
+	_, err := c.Stat(ctx, "/foo")
+	require.ErrorIs(t, err, errLost)
+
+	// The connection is still usable by operations with another context
+	_, err = c.Stat(context.Background(), "/foo")
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Reconnects())
+}
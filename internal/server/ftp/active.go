@@ -0,0 +1,251 @@
+package ftp
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// activeControl wraps the control connection to provide active mode. The
+// ftp library only knows passive mode, so EPSV and PASV commands are
+// answered locally after a PORT or EPRT command has been sent to the
+// server, and the next data connection is accepted on our listener.
+type activeControl struct {
+	net.Conn
+	client  *Client
+	r       *bufio.Reader
+	tp      *textproto.Reader
+	pending []byte
+}
+
+func newActiveControl(conn net.Conn, client *Client) *activeControl {
+	r := bufio.NewReader(conn)
+	return &activeControl{
+		Conn:   conn,
+		client: client,
+		r:      r,
+		tp:     textproto.NewReader(r),
+	}
+}
+
+func (a *activeControl) Read(p []byte) (int, error) {
+	if len(a.pending) > 0 {
+		n := copy(p, a.pending)
+		a.pending = a.pending[n:]
+		return n, nil
+	}
+	return a.r.Read(p)
+}
+
+func (a *activeControl) Write(p []byte) (int, error) {
+	switch cmd := strings.ToUpper(strings.TrimSpace(string(p))); cmd {
+	case "EPSV", "PASV":
+		reply, err := a.port(cmd)
+		if err != nil {
+			return 0, err
+		}
+		a.pending = append(a.pending, reply...)
+		return len(p), nil
+	default:
+		return a.Conn.Write(p)
+	}
+}
+
+// port opens a listener, sends its address to the server and returns the
+// reply expected by the ftp library for the passive command it issued. A
+// listener failing to open is replied as a transient error so the control
+// connection remains usable.
+func (a *activeControl) port(cmd string) (string, error) {
+	listener, err := a.client.listen(a.Conn.LocalAddr())
+	if err != nil {
+		return fmt.Sprintf("%d %s\r\n", ftp.StatusCanNotOpenDataConnection, err), nil
+	}
+
+	addr := listener.Addr().(*net.TCPAddr)
+	ip := addr.IP
+	if len(a.client.cfg.ActiveExternalIP) > 0 {
+		ip = net.ParseIP(a.client.cfg.ActiveExternalIP)
+	} else if local, ok := a.Conn.LocalAddr().(*net.TCPAddr); ok && ip.IsUnspecified() {
+		ip = local.IP
+	}
+
+	var line string
+	if ip4 := ip.To4(); ip4 != nil {
+		line = fmt.Sprintf("PORT %d,%d,%d,%d,%d,%d", ip4[0], ip4[1], ip4[2], ip4[3], addr.Port>>8, addr.Port&0xff)
+	} else {
+		line = fmt.Sprintf("EPRT |2|%s|%d|", ip.String(), addr.Port)
+	}
+	if *a.client.cfg.LogTrace {
+		log.Debug().Msg(line)
+	}
+
+	if _, err = a.Conn.Write([]byte(line + "\r\n")); err != nil {
+		_ = listener.Close()
+		return "", err
+	}
+	code, msg, err := a.tp.ReadResponse(2)
+	if err != nil {
+		_ = listener.Close()
+		if _, ok := err.(*textproto.Error); !ok {
+			return "", err
+		}
+		return fmt.Sprintf("%d %s\r\n", code, msg), nil
+	}
+
+	a.client.setListener(listener)
+	if cmd == "EPSV" {
+		return fmt.Sprintf("229 Entering Extended Passive Mode (|||%d|)\r\n", addr.Port), nil
+	}
+	// The address is not used as the data connection is accepted on the listener
+	return fmt.Sprintf("227 Entering Passive Mode (0,0,0,0,%d,%d)\r\n", addr.Port>>8, addr.Port&0xff), nil
+}
+
+// listen opens a listener for a data connection within the configured port
+// range. The local address of the control connection is used by default.
+func (c *Client) listen(local net.Addr) (net.Listener, error) {
+	host := c.cfg.ActiveListenAddr
+	if len(host) == 0 {
+		if addr, ok := local.(*net.TCPAddr); ok {
+			host = addr.IP.String()
+		}
+	}
+
+	min, max, err := c.cfg.ActivePorts()
+	if err != nil {
+		return nil, err
+	}
+	if min == 0 {
+		return net.Listen("tcp", net.JoinHostPort(host, "0"))
+	}
+
+	start := rand.Intn(max - min + 1)
+	for i := 0; i <= max-min; i++ {
+		port := min + (start+i)%(max-min+1)
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err == nil {
+			return listener, nil
+		}
+	}
+	return nil, errors.Errorf("No port available in FTP active port range %s", c.cfg.ActivePortRange)
+}
+
+// setListener stores the listener of the next data connection
+func (c *Client) setListener(listener net.Listener) {
+	c.listenerMu.Lock()
+	defer c.listenerMu.Unlock()
+	if c.listener != nil {
+		_ = c.listener.Close()
+	}
+	c.listener = listener
+}
+
+// takeListener returns the listener of the next data connection if any
+func (c *Client) takeListener() net.Listener {
+	c.listenerMu.Lock()
+	defer c.listenerMu.Unlock()
+	listener := c.listener
+	c.listener = nil
+	return listener
+}
+
+// activeConn is a data connection accepted from the server on first use.
+// Connections from another address than the one of the control connection
+// are rejected.
+type activeConn struct {
+	listener net.Listener
+	server   net.IP
+	timeout  time.Duration
+	once     sync.Once
+	conn     net.Conn
+	err      error
+}
+
+func (a *activeConn) accept() (net.Conn, error) {
+	a.once.Do(func() {
+		if l, ok := a.listener.(*net.TCPListener); ok {
+			_ = l.SetDeadline(time.Now().Add(a.timeout))
+		}
+		for {
+			conn, err := a.listener.Accept()
+			if err != nil {
+				a.err = errors.Wrap(err, "Cannot accept FTP active data connection")
+				break
+			}
+			if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && !addr.IP.Equal(a.server) {
+				log.Warn().Msgf("Reject FTP active data connection from %s", addr.IP)
+				_ = conn.Close()
+				continue
+			}
+			a.conn = conn
+			break
+		}
+		_ = a.listener.Close()
+	})
+	return a.conn, a.err
+}
+
+func (a *activeConn) Read(p []byte) (int, error) {
+	conn, err := a.accept()
+	if err != nil {
+		return 0, err
+	}
+	return conn.Read(p)
+}
+
+func (a *activeConn) Write(p []byte) (int, error) {
+	conn, err := a.accept()
+	if err != nil {
+		return 0, err
+	}
+	return conn.Write(p)
+}
+
+func (a *activeConn) Close() error {
+	_ = a.listener.Close()
+	if conn, err := a.accept(); err == nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+func (a *activeConn) LocalAddr() net.Addr {
+	return a.listener.Addr()
+}
+
+func (a *activeConn) RemoteAddr() net.Addr {
+	return a.listener.Addr()
+}
+
+func (a *activeConn) SetDeadline(t time.Time) error {
+	conn, err := a.accept()
+	if err != nil {
+		return err
+	}
+	return conn.SetDeadline(t)
+}
+
+func (a *activeConn) SetReadDeadline(t time.Time) error {
+	conn, err := a.accept()
+	if err != nil {
+		return err
+	}
+	return conn.SetReadDeadline(t)
+}
+
+func (a *activeConn) SetWriteDeadline(t time.Time) error {
+	conn, err := a.accept()
+	if err != nil {
+		return err
+	}
+	return conn.SetWriteDeadline(t)
+}
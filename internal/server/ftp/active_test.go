@@ -0,0 +1,96 @@
+package ftp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/internal/server"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// activeMode enables active mode on the client
+func activeMode(cfg *config.ServerFTP) {
+	cfg.ActiveMode = utl.NewTrue()
+}
+
+func TestActivePORT(t *testing.T) {
+	srv := newTestServer(t, "127.0.0.1")
+	srv.put("/src/foo.txt", []byte("foo"), time.Now())
+	c := newServerClient(t, srv, activeMode)
+
+	var dest bytes.Buffer
+	require.NoError(t, c.Retrieve(context.Background(), "/src/foo.txt", &dest))
+	assert.Equal(t, "foo", dest.String())
+
+	require.Len(t, srv.commands("PORT"), 1)
+	assert.Regexp(t, `^PORT 127,0,0,1,\d+,\d+$`, srv.commands("PORT")[0])
+	assert.Empty(t, srv.commands("EPSV", "PASV", "EPRT"))
+}
+
+func TestActiveEPRT(t *testing.T) {
+	srv := newTestServer(t, "::1")
+	srv.put("/src/foo.txt", []byte("foo"), time.Now())
+	c := newServerClient(t, srv, activeMode)
+
+	var dest bytes.Buffer
+	require.NoError(t, c.Retrieve(context.Background(), "/src/foo.txt", &dest))
+	assert.Equal(t, "foo", dest.String())
+
+	require.Len(t, srv.commands("EPRT"), 1)
+	assert.Regexp(t, `^EPRT \|2\|::1\|\d+\|$`, srv.commands("EPRT")[0])
+	assert.Empty(t, srv.commands("EPSV", "PASV", "PORT"))
+}
+
+func TestActiveRejectOtherAddress(t *testing.T) {
+	rogue := net.ParseIP("127.0.0.2")
+	ln, err := net.Listen("tcp", net.JoinHostPort(rogue.String(), "0"))
+	if err != nil {
+		t.Skipf("Cannot use %s: %v", rogue, err)
+	}
+	_ = ln.Close()
+
+	srv := newTestServer(t, "127.0.0.1")
+	srv.rogue = rogue
+	srv.put("/src/foo.txt", []byte("foo"), time.Now())
+	c := newServerClient(t, srv, activeMode)
+
+	// The data connection from another address is closed and the one of
+	// the server is accepted
+	var dest bytes.Buffer
+	require.NoError(t, c.Retrieve(context.Background(), "/src/foo.txt", &dest))
+	assert.Equal(t, "foo", dest.String())
+}
+
+func TestActivePortRangeExhausted(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := busy.Addr().(*net.TCPAddr).Port
+
+	srv := newTestServer(t, "127.0.0.1")
+	srv.put("/src/foo.txt", []byte("foo"), time.Now())
+	c := newServerClient(t, srv, func(cfg *config.ServerFTP) {
+		activeMode(cfg)
+		cfg.ActivePortRange = fmt.Sprintf("%d-%d", port, port)
+	})
+
+	var dest bytes.Buffer
+	err = c.Retrieve(context.Background(), "/src/foo.txt", &dest)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No port available in FTP active port range")
+	assert.False(t, c.ConnLost(err))
+	assert.Equal(t, server.ErrorTransient, c.Classify(err))
+	assert.Empty(t, srv.commands("PORT", "EPRT", "RETR"))
+
+	// The control connection is still usable once the port is available
+	require.NoError(t, busy.Close())
+	require.NoError(t, c.Retrieve(context.Background(), "/src/foo.txt", &dest))
+	assert.Equal(t, "foo", dest.String())
+	assert.Equal(t, []string{fmt.Sprintf("PORT 127,0,0,1,%d,%d", port>>8, port&0xff)}, srv.commands("PORT"))
+}
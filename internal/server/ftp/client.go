@@ -1,34 +1,93 @@
 package ftp
 
 import (
+	"context"
 	"crypto/tls"
-	"fmt"
 	"io"
+	"net"
+	"net/textproto"
 	"os"
+	"path"
 	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/crazy-max/ftpgrab/v7/internal/config"
 	"github.com/crazy-max/ftpgrab/v7/internal/logging"
 	"github.com/crazy-max/ftpgrab/v7/internal/server"
 	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
 	"github.com/jlaffaye/ftp"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
 // Client represents an active ftp object
 type Client struct {
-	*server.Client
-	cfg *config.ServerFTP
-	ftp *ftp.ServerConn
+	cfg        *config.ServerFTP
+	ftp        *ftp.ServerConn
+	mu         sync.Mutex
+	tlsConfig  *tls.Config
+	dialer     *server.Dialer
+	control    bool
+	serverIP   net.IP
+	conns      map[*trackedConn]struct{}
+	connsMu    sync.Mutex
+	listener   net.Listener
+	listenerMu sync.Mutex
+	offset     time.Duration
+	offsetDone bool
+	skew       time.Duration
+	skewDone   bool
+	// controlLost is set once reading or writing the control connection
+	// failed
+	controlLost uint32
 }
 
-// New creates new ftp instance
+// New creates new ftp instance. Each connection has its own client, so time
+// offsets are detected again once reconnected.
 func New(cfg *config.ServerFTP) (*server.Client, error) {
+	return server.New(cfg.Reconnect, func() (server.Handler, error) {
+		client, err := newClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	})
+}
+
+func newClient(cfg *config.ServerFTP) (*Client, error) {
 	var err error
-	var client = &Client{cfg: cfg}
+	var client = &Client{
+		cfg:   cfg,
+		conns: make(map[*trackedConn]struct{}),
+	}
+
+	proxyUsername, err := utl.GetSecret(cfg.ProxyUsername, cfg.ProxyUsernameFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot retrieve proxy username secret for ftp server")
+	}
+	proxyPassword, err := utl.GetSecret(cfg.ProxyPassword, cfg.ProxyPasswordFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot retrieve proxy password secret for ftp server")
+	}
+	if client.dialer, err = server.NewDialer(cfg.Proxy, proxyUsername, proxyPassword, *cfg.Timeout); err != nil {
+		return nil, err
+	}
+	if *cfg.ActiveMode && client.dialer.Proxied() {
+		return nil, errors.New("FTP active mode cannot be used through a proxy")
+	}
+
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid timezone %s", cfg.Timezone)
+	}
 
 	ftpConfig := []ftp.DialOption{
 		ftp.DialWithTimeout(*cfg.Timeout),
+		ftp.DialWithLocation(location),
+		ftp.DialWithDialFunc(client.dial),
 		ftp.DialWithDisabledEPSV(*cfg.DisableEPSV),
 		ftp.DialWithDisabledUTF8(*cfg.DisableUTF8),
 		ftp.DialWithDisabledMLSD(*cfg.DisableMLSD),
@@ -38,13 +97,14 @@ func New(cfg *config.ServerFTP) (*server.Client, error) {
 	}
 
 	if *cfg.TLS {
-		ftpConfig = append(ftpConfig, ftp.DialWithTLS(&tls.Config{
-			ServerName:         cfg.Host,
-			InsecureSkipVerify: *cfg.InsecureSkipVerify,
-		}))
+		if client.tlsConfig, err = newTLSConfig(cfg); err != nil {
+			return nil, err
+		}
+		// TLS is handled when dialing, the option only enables data protection
+		ftpConfig = append(ftpConfig, ftp.DialWithTLS(client.tlsConfig))
 	}
 
-	if client.ftp, err = ftp.Dial(fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), ftpConfig...); err != nil {
+	if client.ftp, err = ftp.Dial(net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)), ftpConfig...); err != nil {
 		return nil, err
 	}
 
@@ -59,11 +119,12 @@ func New(cfg *config.ServerFTP) (*server.Client, error) {
 
 	if len(username) > 0 {
 		if err = client.ftp.Login(username, password); err != nil {
+			_ = client.ftp.Quit()
 			return nil, err
 		}
 	}
 
-	return &server.Client{Handler: client}, err
+	return client, err
 }
 
 // Common return common configuration
@@ -76,18 +137,86 @@ func (c *Client) Common() config.ServerCommon {
 }
 
 // ReadDir fetches the contents of a directory, returning a list of os.FileInfo's
-func (c *Client) ReadDir(dir string) ([]os.FileInfo, error) {
-	var files []*ftp.Entry
+func (c *Client) ReadDir(ctx context.Context, dir string) ([]os.FileInfo, error) {
+	files, err := c.list(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
 
+	entries := make([]os.FileInfo, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, file)
+	}
+
+	return entries, nil
+}
+
+// Stat returns the os.FileInfo of a file or directory, following
+// symbolic links
+func (c *Client) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	for i := 0; i < server.MaxSymlinks; i++ {
+		file, err := c.entry(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if file.mode&os.ModeSymlink == 0 {
+			return file, nil
+		}
+		name = server.ResolveLink(path.Dir(name), file.target)
+	}
+	return nil, errors.Errorf("Too many levels of symbolic links for %s", name)
+}
+
+// ReadLink returns the target of a symbolic link
+func (c *Client) ReadLink(ctx context.Context, name string) (string, error) {
+	file, err := c.entry(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if file.mode&os.ModeSymlink == 0 || len(file.target) == 0 {
+		return "", errors.Errorf("%s is not a symbolic link", name)
+	}
+	return file.target, nil
+}
+
+// entry finds a file or directory by listing its parent directory
+func (c *Client) entry(ctx context.Context, name string) (*fileInfo, error) {
+	name = path.Clean(name)
+	if name == "/" || name == "." {
+		return &fileInfo{name: name, mode: os.ModeDir}, nil
+	}
+
+	files, err := c.list(ctx, path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.name == path.Base(name) {
+			return file, nil
+		}
+	}
+
+	return nil, errors.Wrap(os.ErrNotExist, name)
+}
+
+func (c *Client) list(ctx context.Context, dir string) ([]*fileInfo, error) {
+	pattern := dir
 	if *c.cfg.EscapeRegexpMeta {
-		dir = regexp.QuoteMeta(dir)
+		pattern = regexp.QuoteMeta(dir)
 	}
-	files, err := c.ftp.List(dir)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stop := server.Watch(ctx, c.abort)
+	files, err := c.ftp.List(pattern)
+	if err == nil && !c.offsetDone {
+		c.detectOffset(dir, files)
+	}
+	stop()
 	if err != nil {
 		return nil, err
 	}
 
-	var entries []os.FileInfo
+	var entries []*fileInfo
 	for _, file := range files {
 		if file.Name == "." || file.Name == ".." {
 			continue
@@ -100,10 +229,11 @@ func (c *Client) ReadDir(dir string) ([]os.FileInfo, error) {
 			mode |= os.ModeSymlink
 		}
 		fileInfo := &fileInfo{
-			name:  file.Name,
-			mode:  mode,
-			mtime: file.Time,
-			size:  int64(file.Size),
+			name:   file.Name,
+			mode:   mode,
+			mtime:  file.Time.Add(-c.offset - c.skew),
+			size:   int64(file.Size),
+			target: file.Target,
 		}
 		entries = append(entries, fileInfo)
 	}
@@ -111,8 +241,29 @@ func (c *Client) ReadDir(dir string) ([]os.FileInfo, error) {
 	return entries, nil
 }
 
+// ModTime returns the modification time of a file from MDTM if listings
+// do not provide precise times
+func (c *Client) ModTime(ctx context.Context, name string) (time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer server.Watch(ctx, c.abort)()
+
+	if c.ftp.IsTimePreciseInList() || !c.ftp.IsGetTimeSupported() {
+		return time.Time{}, nil
+	}
+	mtime, err := c.ftp.GetTime(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return mtime.Add(-c.skew), nil
+}
+
 // Retrieve file "path" from server and write bytes to "dest".
-func (c *Client) Retrieve(path string, dest io.Writer) error {
+func (c *Client) Retrieve(ctx context.Context, path string, dest io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer server.Watch(ctx, c.abort)()
+
 	resp, err := c.ftp.Retr(path)
 	if err != nil {
 		return err
@@ -123,7 +274,40 @@ func (c *Client) Retrieve(path string, dest io.Writer) error {
 	return err
 }
 
+// Remove deletes file "path" from server
+func (c *Client) Remove(ctx context.Context, path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer server.Watch(ctx, c.abort)()
+
+	return c.ftp.Delete(path)
+}
+
+// ConnLost checks if an error is caused by a lost connection. Only a 421
+// reply or a failure of the control connection means the connection is
+// lost, data connections failing are transfer errors.
+func (c *Client) ConnLost(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code == ftp.StatusNotAvailable
+	}
+	return atomic.LoadUint32(&c.controlLost) == 1
+}
+
+// Classify returns the class of an error from its FTP reply code. Errors
+// without reply code are considered transient.
+func (c *Client) Classify(err error) server.ErrorClass {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 && protoErr.Code < 600 {
+		return server.ErrorPermanent
+	}
+	return server.ErrorTransient
+}
+
 // Close closes ftp connection
 func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	return c.ftp.Quit()
 }
@@ -0,0 +1,352 @@
+package ftp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/stretchr/testify/require"
+)
+
+type testFile struct {
+	content []byte
+	mtime   time.Time
+}
+
+// testServer is a fake FTP server. Data connections are opened in passive
+// mode with EPSV or PASV and in active mode with PORT or EPRT.
+type testServer struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	files map[string]testFile
+	cmds  []string
+	conns []net.Conn
+	// rogue is an address a data connection is opened from before the
+	// one of the server in active mode
+	rogue net.IP
+	// skew is how far the server clock is from the local one
+	skew time.Duration
+	// listTZ is the location of listing times
+	listTZ *time.Location
+	// readOnly refuses uploads
+	readOnly bool
+}
+
+// newTestServer starts a server listening on host. The test is skipped if
+// the host cannot be listened on.
+func newTestServer(t *testing.T, host string) *testServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		t.Skipf("Cannot listen on %s: %v", host, err)
+	}
+	s := &testServer{
+		ln:     ln,
+		files:  make(map[string]testFile),
+		listTZ: time.UTC,
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) put(name string, content []byte, mtime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[name] = testFile{content: content, mtime: mtime}
+}
+
+// drop closes control connections
+func (s *testServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+// commands returns the commands received having one of the given verbs
+func (s *testServer) commands(verbs ...string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cmds []string
+	for _, cmd := range s.cmds {
+		for _, verb := range verbs {
+			if strings.HasPrefix(cmd, verb) {
+				cmds = append(cmds, cmd)
+			}
+		}
+	}
+	return cmds
+}
+
+// session is a control connection to the server
+type session struct {
+	server  *testServer
+	conn    net.Conn
+	passive net.Listener
+	active  string
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+	sess := &session{server: s, conn: conn}
+	defer sess.reset()
+
+	sess.reply(220, "Ready")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			verb, arg = line[:i], line[i+1:]
+		}
+		verb = strings.ToUpper(verb)
+		s.mu.Lock()
+		s.cmds = append(s.cmds, verb+" "+arg)
+		s.mu.Unlock()
+		if !sess.handle(verb, arg) {
+			return
+		}
+	}
+}
+
+func (sess *session) reply(code int, msg string) {
+	_, _ = fmt.Fprintf(sess.conn, "%d %s\r\n", code, msg)
+}
+
+func (sess *session) handle(verb string, arg string) bool {
+	s := sess.server
+	switch verb {
+	case "USER":
+		sess.reply(331, "Password required")
+	case "PASS":
+		sess.reply(230, "Logged in")
+	case "FEAT":
+		_, _ = io.WriteString(sess.conn, "211-Features:\r\n EPSV\r\n MDTM\r\n UTF8\r\n211 End\r\n")
+	case "TYPE", "OPTS":
+		sess.reply(200, "OK")
+	case "EPSV", "PASV":
+		sess.reset()
+		local := sess.conn.LocalAddr().(*net.TCPAddr)
+		ln, err := net.Listen("tcp", net.JoinHostPort(local.IP.String(), "0"))
+		if err != nil {
+			sess.reply(425, err.Error())
+			return true
+		}
+		sess.passive = ln
+		port := ln.Addr().(*net.TCPAddr).Port
+		if ip4 := local.IP.To4(); verb == "PASV" && ip4 != nil {
+			sess.reply(227, fmt.Sprintf("Entering Passive Mode (%d,%d,%d,%d,%d,%d)", ip4[0], ip4[1], ip4[2], ip4[3], port>>8, port&0xff))
+		} else if verb == "PASV" {
+			sess.reply(425, "PASV is IPv4 only")
+		} else {
+			sess.reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", port))
+		}
+	case "PORT":
+		sess.reset()
+		var h [6]int
+		if _, err := fmt.Sscanf(arg, "%d,%d,%d,%d,%d,%d", &h[0], &h[1], &h[2], &h[3], &h[4], &h[5]); err != nil {
+			sess.reply(501, "Invalid PORT")
+			return true
+		}
+		sess.active = net.JoinHostPort(fmt.Sprintf("%d.%d.%d.%d", h[0], h[1], h[2], h[3]), strconv.Itoa(h[4]<<8|h[5]))
+		sess.reply(200, "PORT OK")
+	case "EPRT":
+		sess.reset()
+		fields := strings.Split(arg, "|")
+		if len(fields) != 5 {
+			sess.reply(501, "Invalid EPRT")
+			return true
+		}
+		sess.active = net.JoinHostPort(fields[2], fields[3])
+		sess.reply(200, "EPRT OK")
+	case "LIST":
+		dir := path.Clean(arg)
+		s.mu.Lock()
+		var lines []string
+		for name, file := range s.files {
+			if path.Dir(name) != dir {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("-rw-r--r-- 1 ftp ftp %d %s %s\r\n", len(file.content), file.mtime.In(s.listTZ).Format("Jan _2 15:04"), path.Base(name)))
+		}
+		s.mu.Unlock()
+		sort.Strings(lines)
+		sess.transfer([]byte(strings.Join(lines, "")))
+	case "RETR":
+		s.mu.Lock()
+		file, ok := s.files[arg]
+		s.mu.Unlock()
+		if !ok {
+			sess.reply(550, "No such file")
+			return true
+		}
+		sess.transfer(file.content)
+	case "STOR":
+		if s.readOnly {
+			sess.reply(550, "Permission denied")
+			return true
+		}
+		sess.receive(arg)
+	case "DELE":
+		s.mu.Lock()
+		_, ok := s.files[arg]
+		delete(s.files, arg)
+		s.mu.Unlock()
+		if !ok {
+			sess.reply(550, "No such file")
+			return true
+		}
+		sess.reply(250, "Deleted")
+	case "MDTM":
+		s.mu.Lock()
+		file, ok := s.files[arg]
+		s.mu.Unlock()
+		if !ok {
+			sess.reply(550, "No such file")
+			return true
+		}
+		sess.reply(213, file.mtime.UTC().Format("20060102150405"))
+	case "QUIT":
+		sess.reply(221, "Bye")
+		return false
+	default:
+		sess.reply(502, "Not implemented")
+	}
+	return true
+}
+
+// transfer sends data over the data connection set up by the last EPSV,
+// PASV, PORT or EPRT command
+func (sess *session) transfer(data []byte) {
+	defer sess.reset()
+	sess.reply(150, "Opening data connection")
+
+	var conn net.Conn
+	var err error
+	switch {
+	case sess.passive != nil:
+		conn, err = sess.passive.Accept()
+	case len(sess.active) > 0:
+		if rogue := sess.server.rogue; rogue != nil {
+			if err = sess.dialRogue(rogue); err != nil {
+				break
+			}
+		}
+		conn, err = net.Dial("tcp", sess.active)
+	default:
+		sess.reply(425, "Use PORT or PASV first")
+		return
+	}
+	if err != nil {
+		sess.reply(425, err.Error())
+		return
+	}
+	_, _ = conn.Write(data)
+	_ = conn.Close()
+	sess.reply(226, "Transfer complete")
+}
+
+// receive stores a file uploaded over the passive data connection. Its
+// modification time is the one of the server clock.
+func (sess *session) receive(name string) {
+	defer sess.reset()
+	if sess.passive == nil {
+		sess.reply(425, "Use PASV first")
+		return
+	}
+	sess.reply(150, "Opening data connection")
+	conn, err := sess.passive.Accept()
+	if err != nil {
+		sess.reply(425, err.Error())
+		return
+	}
+	content, err := io.ReadAll(conn)
+	_ = conn.Close()
+	if err != nil {
+		sess.reply(426, err.Error())
+		return
+	}
+	s := sess.server
+	s.mu.Lock()
+	s.files[name] = testFile{content: content, mtime: time.Now().Add(s.skew)}
+	s.mu.Unlock()
+	sess.reply(226, "Transfer complete")
+}
+
+// dialRogue opens a data connection from another address and waits for it
+// to be closed by the client
+func (sess *session) dialRogue(rogue net.IP) error {
+	dialer := net.Dialer{LocalAddr: &net.TCPAddr{IP: rogue}}
+	conn, err := dialer.Dial("tcp", sess.active)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("rogue"))
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _ = io.Copy(io.Discard, conn)
+	return nil
+}
+
+func (sess *session) reset() {
+	if sess.passive != nil {
+		_ = sess.passive.Close()
+		sess.passive = nil
+	}
+	sess.active = ""
+}
+
+// newServerConfig returns the configuration of the fake server
+func newServerConfig(srv *testServer, setup func(cfg *config.ServerFTP)) *config.ServerFTP {
+	addr := srv.ln.Addr().(*net.TCPAddr)
+	cfg := (&config.ServerFTP{}).GetDefaults()
+	cfg.Host = addr.IP.String()
+	cfg.Port = addr.Port
+	cfg.Username = "demo"
+	cfg.Password = "password"
+	cfg.Timeout = utl.NewDuration(5 * time.Second)
+	cfg.Reconnect.Delay = utl.NewDuration(time.Millisecond)
+	if setup != nil {
+		setup(cfg)
+	}
+	return cfg
+}
+
+// newServerClient connects a client to the fake server
+func newServerClient(t *testing.T, srv *testServer, setup func(cfg *config.ServerFTP)) *Client {
+	t.Helper()
+
+	client, err := newClient(newServerConfig(srv, setup))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
@@ -0,0 +1,117 @@
+package ftp
+
+import (
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+)
+
+// trackedConn is a control or data connection known by the client so it
+// can be closed to interrupt pending operations. Read and write errors of
+// the control connection flag it as lost.
+type trackedConn struct {
+	net.Conn
+	client  *Client
+	control bool
+}
+
+func (t *trackedConn) Read(b []byte) (int, error) {
+	n, err := t.Conn.Read(b)
+	t.check(err)
+	return n, err
+}
+
+func (t *trackedConn) Write(b []byte) (int, error) {
+	n, err := t.Conn.Write(b)
+	t.check(err)
+	return n, err
+}
+
+func (t *trackedConn) check(err error) {
+	if err != nil && t.control {
+		atomic.StoreUint32(&t.client.controlLost, 1)
+	}
+}
+
+// RemoteAddr returns the address of the server. The address is unspecified
+// through a proxy as the server address is not known.
+func (t *trackedConn) RemoteAddr() net.Addr {
+	if addr, ok := t.Conn.RemoteAddr().(*net.TCPAddr); ok && !t.client.dialer.Proxied() {
+		return addr
+	}
+	return &net.TCPAddr{IP: net.IPv4zero}
+}
+
+func (t *trackedConn) Close() error {
+	t.client.connsMu.Lock()
+	delete(t.client.conns, t)
+	t.client.connsMu.Unlock()
+	return t.Conn.Close()
+}
+
+// dial opens control and data connections. Through a proxy, data
+// connections to the unspecified address are opened to the server host.
+// In active mode, data connections are accepted from the server instead.
+func (c *Client) dial(network string, address string) (net.Conn, error) {
+	if listener := c.takeListener(); listener != nil {
+		return c.wrap(&activeConn{listener: listener, server: c.serverIP, timeout: *c.cfg.Timeout}, false)
+	}
+
+	if host, port, err := net.SplitHostPort(address); err == nil && c.dialer.Proxied() && net.ParseIP(host).IsUnspecified() {
+		address = net.JoinHostPort(c.cfg.Host, port)
+	}
+
+	conn, err := c.dialer.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	control := !c.control
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && control {
+		c.serverIP = addr.IP
+	}
+	c.control = true
+	return c.wrap(conn, control)
+}
+
+// wrap tracks the connection and sets up TLS. In explicit mode, the control
+// connection is upgraded with AUTH TLS while data connections are always
+// protected. The control connection handles active mode if enabled.
+func (c *Client) wrap(conn net.Conn, control bool) (net.Conn, error) {
+	tc := &trackedConn{Conn: conn, client: c, control: control}
+	c.connsMu.Lock()
+	c.conns[tc] = struct{}{}
+	c.connsMu.Unlock()
+
+	var err error
+	conn = tc
+	if c.tlsConfig != nil {
+		if control && c.cfg.TLSMode == "explicit" {
+			conn, err = authTLS(tc, c.tlsConfig, *c.cfg.Timeout)
+		} else {
+			conn = tls.Client(tc, c.tlsConfig)
+		}
+		if err != nil {
+			_ = tc.Close()
+			return nil, err
+		}
+	}
+	if control && *c.cfg.ActiveMode {
+		conn = newActiveControl(conn, c)
+	}
+
+	return conn, nil
+}
+
+// abort closes all connections to the server to interrupt pending operations
+func (c *Client) abort() {
+	if listener := c.takeListener(); listener != nil {
+		_ = listener.Close()
+	}
+	c.connsMu.Lock()
+	defer c.connsMu.Unlock()
+	for conn := range c.conns {
+		_ = conn.Conn.Close()
+		delete(c.conns, conn)
+	}
+}
@@ -0,0 +1,51 @@
+package ftp
+
+import (
+	"io"
+	"net"
+	"net/textproto"
+	"testing"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/jlaffaye/ftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient() *Client {
+	return &Client{
+		cfg:   (&config.ServerFTP{}).GetDefaults(),
+		conns: make(map[*trackedConn]struct{}),
+	}
+}
+
+// brokenConn returns a connection tracked by c whose peer is closed
+func brokenConn(t *testing.T, c *Client, control bool) net.Conn {
+	t.Helper()
+
+	local, remote := net.Pipe()
+	_ = remote.Close()
+	conn, err := c.wrap(local, control)
+	require.NoError(t, err)
+	return conn
+}
+
+func TestConnLostData(t *testing.T) {
+	c := newTestClient()
+	_, err := brokenConn(t, c, false).Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+	assert.False(t, c.ConnLost(err))
+}
+
+func TestConnLostControl(t *testing.T) {
+	c := newTestClient()
+	_, err := brokenConn(t, c, true).Write([]byte("NOOP\r\n"))
+	require.Error(t, err)
+	assert.True(t, c.ConnLost(err))
+}
+
+func TestConnLostReply(t *testing.T) {
+	c := newTestClient()
+	assert.True(t, c.ConnLost(&textproto.Error{Code: ftp.StatusNotAvailable, Msg: "Service not available"}))
+	assert.False(t, c.ConnLost(&textproto.Error{Code: ftp.StatusFileUnavailable, Msg: "No such file"}))
+}
@@ -6,10 +6,11 @@ import (
 )
 
 type fileInfo struct {
-	name  string
-	size  int64
-	mode  os.FileMode
-	mtime time.Time
+	name   string
+	size   int64
+	mode   os.FileMode
+	mtime  time.Time
+	target string
 }
 
 func (f *fileInfo) Name() string {
@@ -0,0 +1,103 @@
+package ftp
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxOffset is the largest offset accepted between listing and MDTM times
+	// and between the server and local clocks
+	maxOffset = 26 * time.Hour
+
+	// recentAge is the age below which listings include the time of day
+	recentAge = 180 * 24 * time.Hour
+
+	// probeName is the name of the file uploaded to detect the clock skew
+	probeName = ".ftpgrab-probe"
+)
+
+// detectOffset finds how far modification times of the server are from the
+// local ones. The skew of the server clock is detected once with a probe
+// file, then the listing time of a recent file is compared with its MDTM
+// time, which is always UTC, to find the offset of listing times. This
+// covers servers listing files in an unknown timezone.
+func (c *Client) detectOffset(dir string, files []*ftp.Entry) {
+	if !*c.cfg.DetectOffset || !c.ftp.IsGetTimeSupported() {
+		c.offsetDone = true
+		return
+	}
+
+	if !c.skewDone {
+		c.skewDone = true
+		c.detectSkew(dir)
+	}
+	if c.ftp.IsTimePreciseInList() {
+		c.offsetDone = true
+		return
+	}
+
+	for _, file := range files {
+		if file.Type != ftp.EntryTypeFile || time.Since(file.Time) > recentAge {
+			continue
+		}
+		c.offsetDone = true
+
+		mtime, err := c.ftp.GetTime(path.Join(dir, file.Name))
+		if err != nil {
+			log.Debug().Err(err).Msgf("Cannot detect offset of FTP listing times with %s", file.Name)
+			return
+		}
+
+		offset := file.Time.Sub(mtime.Truncate(time.Minute)).Round(time.Minute)
+		if offset > maxOffset || offset < -maxOffset {
+			log.Debug().Msgf("Ignore unlikely offset of %s for FTP listing times", offset)
+			return
+		} else if offset != 0 {
+			log.Info().Msgf("FTP listing times are %s off, adjusting modtimes", offset)
+		}
+		c.offset = offset
+		return
+	}
+}
+
+// detectSkew uploads an empty probe file to dir and compares its MDTM time
+// with the local time of the upload to find how far the server clock is
+// from the local one. The clock is assumed in sync if the probe cannot be
+// uploaded.
+func (c *Client) detectSkew(dir string) {
+	name := path.Join(dir, probeName)
+	before := time.Now()
+	if err := c.ftp.Stor(name, strings.NewReader("")); err != nil {
+		log.Debug().Err(err).Msg("Cannot upload probe file to detect FTP server clock skew")
+		return
+	}
+	after := time.Now()
+	defer func() {
+		if err := c.ftp.Delete(name); err != nil {
+			log.Warn().Err(err).Msgf("Cannot remove FTP probe file %s", name)
+		}
+	}()
+
+	mtime, err := c.ftp.GetTime(name)
+	if err != nil {
+		log.Debug().Err(err).Msg("Cannot detect FTP server clock skew")
+		return
+	}
+
+	// MDTM times are precise to the second
+	if !mtime.Before(before.Truncate(time.Second).Add(-time.Second)) && !mtime.After(after.Add(time.Second)) {
+		return
+	}
+	skew := mtime.Sub(before.Add(after.Sub(before) / 2)).Round(time.Second)
+	if skew > maxOffset || skew < -maxOffset {
+		log.Debug().Msgf("Ignore unlikely skew of %s for FTP server clock", skew)
+		return
+	}
+	log.Info().Msgf("FTP server clock is %s off, adjusting modtimes", skew)
+	c.skew = skew
+}
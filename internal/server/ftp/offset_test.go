@@ -0,0 +1,108 @@
+package ftp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectSkew(t *testing.T) {
+	srv := newTestServer(t, "127.0.0.1")
+	srv.skew = 2 * time.Hour
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	srv.put("/src/foo.txt", []byte("foo"), mtime.Add(srv.skew))
+	c := newServerClient(t, srv, nil)
+
+	entries, err := c.ReadDir(context.Background(), "/src")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.WithinDuration(t, mtime, entries[0].ModTime(), time.Minute)
+
+	precise, err := c.ModTime(context.Background(), "/src/foo.txt")
+	require.NoError(t, err)
+	assert.WithinDuration(t, mtime, precise, time.Second)
+
+	// The probe file is removed and only uploaded once
+	_, err = c.ReadDir(context.Background(), "/src")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"STOR /src/" + probeName}, srv.commands("STOR"))
+	assert.Equal(t, []string{"DELE /src/" + probeName}, srv.commands("DELE"))
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	assert.NotContains(t, srv.files, "/src/"+probeName)
+}
+
+func TestDetectOffsetTimezone(t *testing.T) {
+	srv := newTestServer(t, "127.0.0.1")
+	srv.listTZ = time.FixedZone("UTC+5", 5*3600)
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	srv.put("/src/foo.txt", []byte("foo"), mtime)
+	c := newServerClient(t, srv, nil)
+
+	entries, err := c.ReadDir(context.Background(), "/src")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.WithinDuration(t, mtime, entries[0].ModTime(), time.Minute)
+	assert.Equal(t, 5*time.Hour, c.offset)
+	assert.Zero(t, c.skew)
+}
+
+func TestDetectSkewReadOnly(t *testing.T) {
+	srv := newTestServer(t, "127.0.0.1")
+	srv.readOnly = true
+	srv.skew = 2 * time.Hour
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	srv.put("/src/foo.txt", []byte("foo"), mtime)
+	c := newServerClient(t, srv, nil)
+
+	// The clock is assumed in sync if the probe cannot be uploaded
+	entries, err := c.ReadDir(context.Background(), "/src")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.WithinDuration(t, mtime, entries[0].ModTime(), time.Minute)
+	assert.Zero(t, c.skew)
+	assert.Empty(t, srv.commands("DELE"))
+}
+
+func TestDetectOffsetDisabled(t *testing.T) {
+	srv := newTestServer(t, "127.0.0.1")
+	srv.listTZ = time.FixedZone("UTC+5", 5*3600)
+	srv.put("/src/foo.txt", []byte("foo"), time.Now().Add(-time.Hour))
+	c := newServerClient(t, srv, func(cfg *config.ServerFTP) {
+		cfg.DetectOffset = utl.NewFalse()
+	})
+
+	_, err := c.ReadDir(context.Background(), "/src")
+	require.NoError(t, err)
+	assert.Zero(t, c.offset)
+	assert.Empty(t, srv.commands("STOR", "MDTM"))
+}
+
+func TestDetectOffsetReconnect(t *testing.T) {
+	srv := newTestServer(t, "127.0.0.1")
+	srv.skew = 2 * time.Hour
+	srv.listTZ = time.FixedZone("UTC+5", 5*3600)
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	srv.put("/src/foo.txt", []byte("foo"), mtime.Add(srv.skew))
+
+	client, err := New(newServerConfig(srv, nil))
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.ReadDir(context.Background(), "/src")
+	require.NoError(t, err)
+
+	// Offsets are detected again on the new connection
+	srv.drop()
+	entries, err := client.ReadDir(context.Background(), "/src")
+	require.NoError(t, err)
+	assert.Equal(t, 1, client.Reconnects())
+	require.Len(t, entries, 1)
+	assert.WithinDuration(t, mtime, entries[0].ModTime(), time.Minute)
+	assert.Len(t, srv.commands("STOR"), 2)
+}
@@ -0,0 +1,105 @@
+package ftp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/jlaffaye/ftp"
+	"github.com/pkg/errors"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig creates the TLS configuration shared by control and data
+// connections. The session cache lets data connections resume the TLS
+// session of the control connection, as required by servers enforcing
+// session reuse.
+func newTLSConfig(cfg *config.ServerFTP) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.Host,
+		InsecureSkipVerify: *cfg.InsecureSkipVerify,
+		MinVersion:         tlsVersions[cfg.TLSMinVersion],
+		ClientSessionCache: tls.NewLRUClientSessionCache(0),
+	}
+
+	if len(cfg.CAFile) > 0 {
+		caCert, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Cannot read CA file")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, errors.Errorf("No valid certificate found in CA file %s", cfg.CAFile)
+		}
+	}
+
+	if len(cfg.CertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Cannot load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// authTLS upgrades a control connection in explicit mode. The greeting of
+// the server is read first and replayed to the ftp library once upgraded.
+func authTLS(conn net.Conn, tlsConfig *tls.Config, timeout time.Duration) (net.Conn, error) {
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	defer func() {
+		_ = conn.SetDeadline(time.Time{})
+	}()
+
+	tp := textproto.NewConn(conn)
+	_, greeting, err := tp.ReadResponse(ftp.StatusReady)
+	if err != nil {
+		return nil, err
+	}
+	if err = tp.PrintfLine("AUTH TLS"); err != nil {
+		return nil, err
+	}
+	if _, _, err = tp.ReadResponse(ftp.StatusAuthOK); err != nil {
+		return nil, errors.Wrap(err, "Cannot upgrade connection with AUTH TLS")
+	}
+
+	lines := strings.Split(greeting, "\n")
+	var replay strings.Builder
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		replay.WriteString(fmt.Sprintf("%d%s%s\r\n", ftp.StatusReady, sep, line))
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	return &greetingConn{
+		Conn: tlsConn,
+		r:    io.MultiReader(strings.NewReader(replay.String()), tlsConn),
+	}, nil
+}
+
+// greetingConn reads the replayed greeting before the upgraded connection
+type greetingConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (g *greetingConn) Read(p []byte) (int, error) {
+	return g.r.Read(p)
+}
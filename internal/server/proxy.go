@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/proxy"
+)
+
+// Dialer opens TCP connections, through a SOCKS5 or HTTP CONNECT proxy if
+// defined
+type Dialer struct {
+	proxy   *url.URL
+	timeout time.Duration
+}
+
+// NewDialer creates a dialer using proxyURL, or the ALL_PROXY environment
+// variable if empty. Username and password override credentials of the URL.
+func NewDialer(proxyURL string, username string, password string, timeout time.Duration) (*Dialer, error) {
+	d := &Dialer{timeout: timeout}
+	if len(proxyURL) == 0 {
+		if proxyURL = os.Getenv("ALL_PROXY"); len(proxyURL) == 0 {
+			proxyURL = os.Getenv("all_proxy")
+		}
+	}
+	if len(proxyURL) == 0 {
+		return d, nil
+	}
+
+	var err error
+	if d.proxy, err = url.Parse(proxyURL); err != nil {
+		return nil, errors.Wrap(err, "Cannot parse proxy URL")
+	}
+	switch d.proxy.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return nil, errors.Errorf("Unsupported proxy scheme '%s'", d.proxy.Scheme)
+	}
+	if len(username) > 0 {
+		d.proxy.User = url.UserPassword(username, password)
+	}
+
+	log.Debug().Msgf("Using proxy %s", d.proxy.Redacted())
+	return d, nil
+}
+
+// Proxied reports whether connections go through a proxy
+func (d *Dialer) Proxied() bool {
+	return d.proxy != nil
+}
+
+// Dial connects to address
+func (d *Dialer) Dial(network string, address string) (net.Conn, error) {
+	direct := &net.Dialer{Timeout: d.timeout}
+	if d.proxy == nil {
+		return direct.Dial(network, address)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	if d.proxy.Scheme == "http" {
+		return d.dialHTTP(ctx, direct, network, address)
+	}
+
+	var auth *proxy.Auth
+	if d.proxy.User != nil {
+		password, _ := d.proxy.User.Password()
+		auth = &proxy.Auth{User: d.proxy.User.Username(), Password: password}
+	}
+	socks, err := proxy.SOCKS5("tcp", d.proxy.Host, auth, direct)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := socks.(proxy.ContextDialer).DialContext(ctx, network, address)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot connect through proxy")
+	}
+	return conn, nil
+}
+
+// dialHTTP opens a tunnel to address with the HTTP CONNECT method
+func (d *Dialer) dialHTTP(ctx context.Context, direct *net.Dialer, network string, address string) (net.Conn, error) {
+	conn, err := direct.DialContext(ctx, network, d.proxy.Host)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot connect to proxy")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if d.proxy.User != nil {
+		password, _ := d.proxy.User.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(d.proxy.User.Username()+":"+password)))
+	}
+	if err = req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "Cannot send proxy request")
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "Cannot read proxy response")
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, errors.Errorf("Cannot connect through proxy: %s", resp.Status)
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// bufferedConn reads data received along with the proxy response first
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
@@ -0,0 +1,135 @@
+package sftp
+
+import (
+	"net"
+	"os"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/crazy-max/ftpgrab/v7/pkg/utl"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// authMethods returns ssh authentication methods in the order they are
+// tried and the connection to the ssh agent if used, to be closed once
+// authenticated
+func authMethods(cfg *config.ServerSFTP) ([]ssh.AuthMethod, net.Conn, error) {
+	methods := cfg.AuthMethods
+	if len(methods) == 0 {
+		if len(cfg.KeyFile) > 0 {
+			methods = []string{"publickey"}
+		} else if len(cfg.Password) > 0 || len(cfg.PasswordFile) > 0 {
+			methods = []string{"password"}
+		}
+	}
+
+	var auths []ssh.AuthMethod
+	var agentConn net.Conn
+	for _, method := range methods {
+		switch method {
+		case "agent":
+			socket := os.Getenv("SSH_AUTH_SOCK")
+			if len(socket) == 0 {
+				return nil, nil, errors.New("SSH agent authentication requires SSH_AUTH_SOCK")
+			}
+			conn, err := net.Dial("unix", socket)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "Cannot connect to SSH agent")
+			}
+			agentConn = conn
+			auths = append(auths, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		case "publickey":
+			keyPassphrase, err := utl.GetSecret(cfg.KeyPassphrase, cfg.KeyPassphraseFile)
+			if err != nil {
+				log.Warn().Err(err).Msg("Cannot retrieve key passphrase secret for sftp server")
+			}
+			signer, err := readSigner(cfg.KeyFile, keyPassphrase, cfg.CertFile)
+			if err != nil {
+				closeConn(agentConn)
+				return nil, nil, errors.Wrap(err, "Unable to read SFTP public key")
+			}
+			auths = append(auths, ssh.PublicKeys(signer))
+		case "password":
+			auths = append(auths, ssh.Password(password(cfg)))
+		case "keyboard-interactive":
+			pass := password(cfg)
+			auths = append(auths, ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range questions {
+					answers[i] = pass
+				}
+				return answers, nil
+			}))
+		}
+	}
+
+	return auths, agentConn, nil
+}
+
+// readSigner reads a private key and its OpenSSH certificate if any
+func readSigner(keyFile string, passphrase string, certFile string) (ssh.Signer, error) {
+	buffer, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var signer ssh.Signer
+	if len(passphrase) > 0 {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(buffer, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(buffer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(certFile) == 0 {
+		return signer, nil
+	}
+
+	buffer, err = os.ReadFile(certFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot read certificate")
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot parse certificate")
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.Errorf("%s is not an OpenSSH certificate", certFile)
+	}
+	return ssh.NewCertSigner(cert, signer)
+}
+
+func password(cfg *config.ServerSFTP) string {
+	password, err := utl.GetSecret(cfg.Password, cfg.PasswordFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot retrieve password secret for sftp server")
+	}
+	return password
+}
+
+func closeConn(conn net.Conn) {
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
+
+// hostKeyCallback verifies the host key of a server against a known key or
+// a known_hosts file. Host keys are not verified if none of them is defined.
+func hostKeyCallback(server *config.ServerSFTP) (ssh.HostKeyCallback, error) {
+	if len(server.KnownHostsFile) > 0 {
+		return knownhosts.New(server.KnownHostsFile)
+	} else if len(server.HostKey) > 0 {
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(server.HostKey))
+		if err != nil {
+			return nil, err
+		}
+		return ssh.FixedHostKey(pub), nil
+	}
+	log.Warn().Msgf("Host key of %s is not verified", server.Host)
+	return ssh.InsecureIgnoreHostKey(), nil
+}
@@ -0,0 +1,88 @@
+package sftp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/crazy-max/ftpgrab/v7/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return key
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	key := newHostKey(t)
+	other := newHostKey(t)
+	addr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{"10.0.0.1"}, key)+"\n"), 0o600))
+
+	cases := []struct {
+		name    string
+		server  *config.ServerSFTP
+		key     ssh.PublicKey
+		wantErr bool
+	}{
+		{
+			name:   "host key",
+			server: &config.ServerSFTP{Host: "10.0.0.1", HostKey: string(ssh.MarshalAuthorizedKey(key))},
+			key:    key,
+		},
+		{
+			name:    "host key mismatch",
+			server:  &config.ServerSFTP{Host: "10.0.0.1", HostKey: string(ssh.MarshalAuthorizedKey(key))},
+			key:     other,
+			wantErr: true,
+		},
+		{
+			name:   "known hosts file",
+			server: &config.ServerSFTP{Host: "10.0.0.1", KnownHostsFile: knownHostsFile},
+			key:    key,
+		},
+		{
+			name:    "known hosts file mismatch",
+			server:  &config.ServerSFTP{Host: "10.0.0.1", KnownHostsFile: knownHostsFile},
+			key:     other,
+			wantErr: true,
+		},
+		{
+			name:   "not verified",
+			server: &config.ServerSFTP{Host: "10.0.0.1"},
+			key:    other,
+		},
+	}
+	for _, tt := range cases {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			callback, err := hostKeyCallback(tt.server)
+			require.NoError(t, err)
+			err = callback("10.0.0.1:22", addr, tt.key)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHostKeyCallbackInvalid(t *testing.T) {
+	_, err := hostKeyCallback(&config.ServerSFTP{Host: "10.0.0.1", HostKey: "ssh-ed25519 invalid"})
+	assert.Error(t, err)
+}
@@ -1,9 +1,12 @@
 package sftp
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net"
 	"os"
+	"time"
 
 	"github.com/crazy-max/ftpgrab/v7/internal/config"
 	"github.com/crazy-max/ftpgrab/v7/internal/server"
@@ -16,61 +19,138 @@ import (
 
 // Client represents an active sftp object
 type Client struct {
-	*server.Client
 	config *config.ServerSFTP
 	sftp   *sftp.Client
 	ssh    *ssh.Client
+	jumps  []*ssh.Client
+	dialer *server.Dialer
+	// closed is closed once the sftp session ends
+	closed chan struct{}
 }
 
-// New creates new ftp instance
+// New creates new sftp instance
 func New(config *config.ServerSFTP) (*server.Client, error) {
+	return server.New(config.Reconnect, func() (server.Handler, error) {
+		client, err := newClient(config)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	})
+}
+
+func newClient(config *config.ServerSFTP) (*Client, error) {
 	var err error
-	var client = &Client{config: config}
-	var sshConf *ssh.ClientConfig
-	var sshAuth []ssh.AuthMethod
+	var client = &Client{config: config, closed: make(chan struct{})}
 
-	// SSH Auth
-	if len(config.KeyFile) > 0 {
-		keyPassphrase, err := utl.GetSecret(config.KeyPassphrase, config.KeyPassphraseFile)
+	proxyUsername, err := utl.GetSecret(config.ProxyUsername, config.ProxyUsernameFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot retrieve proxy username secret for sftp server")
+	}
+	proxyPassword, err := utl.GetSecret(config.ProxyPassword, config.ProxyPasswordFile)
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot retrieve proxy password secret for sftp server")
+	}
+	if client.dialer, err = server.NewDialer(config.Proxy, proxyUsername, proxyPassword, *config.Timeout); err != nil {
+		return nil, err
+	}
+
+	// Jump hosts
+	for _, jump := range config.ProxyJump {
+		hostKeyCallback, err := hostKeyCallback(jump.Server())
 		if err != nil {
-			log.Warn().Err(err).Msg("Cannot retrieve key passphrase secret for sftp server")
+			client.closeJumps()
+			return nil, errors.Wrapf(err, "Cannot load host key of jump host %s", jump.Host)
 		}
-		if sshAuth, err = client.readPublicKey(config.KeyFile, keyPassphrase); err != nil {
-			return nil, errors.Wrap(err, "Unable to read SFTP public key")
-		}
-	} else if len(config.Password) > 0 || len(config.PasswordFile) > 0 {
-		password, err := utl.GetSecret(config.Password, config.PasswordFile)
+		hop, err := client.dial(jump.Server(), hostKeyCallback)
 		if err != nil {
-			log.Warn().Err(err).Msg("Cannot retrieve password secret for sftp server")
-		}
-		sshAuth = []ssh.AuthMethod{
-			ssh.Password(password),
+			client.closeJumps()
+			return nil, errors.Wrapf(err, "Cannot connect to jump host %s", jump.Host)
 		}
+		log.Debug().Msgf("Connected to jump host %s", jump.Host)
+		client.jumps = append(client.jumps, hop)
 	}
 
-	username, err := utl.GetSecret(config.Username, config.UsernameFile)
+	hostKeyCallback, err := hostKeyCallback(config)
+	if err != nil {
+		client.closeJumps()
+		return nil, errors.Wrap(err, "Cannot load host key of sftp server")
+	}
+	if client.ssh, err = client.dial(config, hostKeyCallback); err != nil {
+		client.closeJumps()
+		return nil, errors.Wrap(err, "Cannot open ssh connection")
+	}
+
+	if client.sftp, err = sftp.NewClient(client.ssh, sftp.MaxPacket(config.MaxPacketSize)); err != nil {
+		_ = client.ssh.Close()
+		client.closeJumps()
+		return nil, err
+	}
+	go func() {
+		_ = client.sftp.Wait()
+		close(client.closed)
+	}()
+
+	return client, nil
+}
+
+// dial opens a ssh connection to a server, through the last jump host
+// connected if any or the proxy otherwise
+func (c *Client) dial(server *config.ServerSFTP, hostKeyCallback ssh.HostKeyCallback) (*ssh.Client, error) {
+	// SSH Auth
+	sshAuth, agentConn, err := authMethods(server)
+	if err != nil {
+		return nil, err
+	}
+	defer closeConn(agentConn)
+
+	username, err := utl.GetSecret(server.Username, server.UsernameFile)
 	if err != nil {
 		log.Warn().Err(err).Msg("Cannot retrieve username secret for sftp server")
 	}
 
-	sshConf = &ssh.ClientConfig{
+	sshConf := &ssh.ClientConfig{
 		User:            username,
 		Auth:            sshAuth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         *config.Timeout,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         *c.config.Timeout,
 	}
-
 	sshConf.SetDefaults()
-	client.ssh, err = ssh.Dial("tcp", fmt.Sprintf("%s:%d", config.Host, config.Port), sshConf)
+
+	var conn net.Conn
+	addr := fmt.Sprintf("%s:%d", server.Host, server.Port)
+	if len(c.jumps) > 0 {
+		conn, err = c.jumps[len(c.jumps)-1].Dial("tcp", addr)
+	} else {
+		conn, err = c.dialer.Dial("tcp", addr)
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "Cannot open ssh connection")
+		return nil, err
 	}
 
-	if client.sftp, err = sftp.NewClient(client.ssh, sftp.MaxPacket(config.MaxPacketSize)); err != nil {
+	// Connections through a jump host do not support deadlines
+	timer := time.AfterFunc(*c.config.Timeout, func() {
+		_ = conn.Close()
+	})
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, sshConf)
+	if !timer.Stop() {
+		if err == nil {
+			_ = sshConn.Close()
+		}
+		return nil, errors.Errorf("Timeout connecting to %s", addr)
+	} else if err != nil {
+		_ = conn.Close()
 		return nil, err
 	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
 
-	return &server.Client{Handler: client}, err
+// closeJumps closes connections to jump hosts, from the last one
+func (c *Client) closeJumps() {
+	for i := len(c.jumps) - 1; i >= 0; i-- {
+		_ = c.jumps[i].Close()
+	}
+	c.jumps = nil
 }
 
 // Common return common configuration
@@ -82,34 +162,35 @@ func (c *Client) Common() config.ServerCommon {
 	}
 }
 
-func (c *Client) readPublicKey(key string, password string) ([]ssh.AuthMethod, error) {
-	var signer ssh.Signer
-	var err error
-
-	buffer, err := os.ReadFile(key)
-	if err != nil {
-		return nil, err
-	}
+// ReadDir fetches the contents of a directory, returning a list of os.FileInfo's
+func (c *Client) ReadDir(ctx context.Context, path string) ([]os.FileInfo, error) {
+	defer server.Watch(ctx, c.abort)()
+	return c.sftp.ReadDir(path)
+}
 
-	if len(password) > 0 {
-		signer, err = ssh.ParsePrivateKeyWithPassphrase(buffer, []byte(password))
-	} else {
-		signer, err = ssh.ParsePrivateKey(buffer)
-	}
-	if err != nil {
-		return nil, err
-	}
+// Stat returns the os.FileInfo of a file or directory, following
+// symbolic links
+func (c *Client) Stat(ctx context.Context, path string) (os.FileInfo, error) {
+	defer server.Watch(ctx, c.abort)()
+	return c.sftp.Stat(path)
+}
 
-	return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+// ReadLink returns the target of a symbolic link
+func (c *Client) ReadLink(ctx context.Context, path string) (string, error) {
+	defer server.Watch(ctx, c.abort)()
+	return c.sftp.ReadLink(path)
 }
 
-// ReadDir fetches the contents of a directory, returning a list of os.FileInfo's
-func (c *Client) ReadDir(path string) ([]os.FileInfo, error) {
-	return c.sftp.ReadDir(path)
+// ModTime returns a zero time as modification times returned by ReadDir
+// are already precise
+func (c *Client) ModTime(_ context.Context, _ string) (time.Time, error) {
+	return time.Time{}, nil
 }
 
 // Retrieve file "path" from server and write bytes to "dest".
-func (c *Client) Retrieve(path string, dest io.Writer) error {
+func (c *Client) Retrieve(ctx context.Context, path string, dest io.Writer) error {
+	defer server.Watch(ctx, c.abort)()
+
 	reader, err := c.sftp.Open(path)
 	if err != nil {
 		return err
@@ -123,8 +204,50 @@ func (c *Client) Retrieve(path string, dest io.Writer) error {
 	return nil
 }
 
+// Remove deletes file "path" from server
+func (c *Client) Remove(ctx context.Context, path string) error {
+	defer server.Watch(ctx, c.abort)()
+	return c.sftp.Remove(path)
+}
+
+// abort closes the ssh connection to interrupt pending operations
+func (c *Client) abort() {
+	_ = c.ssh.Close()
+}
+
+// ConnLost checks if an error is caused by a lost connection. Other errors
+// only mean the connection is lost once the sftp session has ended.
+func (c *Client) ConnLost(err error) bool {
+	if errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, sftp.ErrSSHFxNoConnection) {
+		return true
+	}
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Classify returns the class of an error from its SFTP status code.
+// Errors without status code are considered transient.
+func (c *Client) Classify(err error) server.ErrorClass {
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+		return server.ErrorPermanent
+	}
+	var statusErr *sftp.StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.FxCode() {
+		case sftp.ErrSSHFxNoSuchFile, sftp.ErrSSHFxPermissionDenied, sftp.ErrSSHFxBadMessage, sftp.ErrSSHFxOpUnsupported:
+			return server.ErrorPermanent
+		}
+	}
+	return server.ErrorTransient
+}
+
 // Close closes sftp connection
 func (c *Client) Close() error {
+	defer c.closeJumps()
 	if err := c.ssh.Close(); err != nil {
 		return err
 	}
@@ -6,9 +6,12 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"time"
 )
 
+var durationDaysRe = regexp.MustCompile(`(\d+(?:\.\d+)?)([dw])`)
+
 // GetEnv retrieves the value of the environment variable named by the key
 // or fallback if not found
 func GetEnv(key, fallback string) string {
@@ -84,3 +87,25 @@ func NewTrue() *bool {
 func NewDuration(duration time.Duration) *time.Duration {
 	return &duration
 }
+
+// ParseDuration parses a duration string like time.ParseDuration but also
+// accepts days ("d") and weeks ("w") units such as "7d" or "1w2d12h"
+func ParseDuration(s string) (time.Duration, error) {
+	var perr error
+	conv := durationDaysRe.ReplaceAllStringFunc(s, func(m string) string {
+		sm := durationDaysRe.FindStringSubmatch(m)
+		v, err := strconv.ParseFloat(sm[1], 64)
+		if err != nil {
+			perr = err
+			return m
+		}
+		if sm[2] == "w" {
+			v *= 7
+		}
+		return strconv.FormatFloat(v*24, 'f', -1, 64) + "h"
+	})
+	if perr != nil {
+		return 0, perr
+	}
+	return time.ParseDuration(conv)
+}